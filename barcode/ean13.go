@@ -0,0 +1,325 @@
+// Package barcode reads EAN-13 barcodes, the kind printed on the back of
+// books, from photos.
+//
+// The decoder scans lines across the image in both directions, turns each
+// into runs of dark and light, and looks for the 59 runs of an EAN-13
+// symbol: a start guard, six digits, a centre guard, six digits and an end
+// guard. Digits are matched by the relative widths of their bars, so the
+// barcode may be any size and need not be perfectly sharp, though the
+// narrowest bars should be at least two pixels wide. Lines that decode with
+// a valid check digit vote, and the most common reading wins.
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+var (
+	// ErrNotFound is returned when no EAN-13 barcode could be read.
+	ErrNotFound = errors.New("no EAN-13 barcode found")
+	// ErrTooLarge is returned for images of more than MaxPixels.
+	ErrTooLarge = errors.New("image is larger than 40 megapixels")
+)
+
+// MaxPixels is the largest image Decode accepts, more than phone cameras
+// take. A small compressed file can claim enormous dimensions, so they are
+// checked before any pixels are decoded.
+const MaxPixels = 40_000_000
+
+// scanLines is how many rows, and how many columns, are scanned.
+const scanLines = 60
+
+// minVotes is how many scan lines must agree on a code.
+const minVotes = 2
+
+// maxDistance is how far, in modules summed over a digit's four runs, the
+// runs may be from the closest digit pattern.
+const maxDistance = 1.6
+
+// Decode reads an image in JPEG, PNG or GIF format and returns the 13
+// digits of the EAN-13 barcode in it. Images of more than MaxPixels are
+// refused with ErrTooLarge.
+func Decode(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return "", ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return DecodeImage(img)
+}
+
+// DecodeImage returns the 13 digits of the EAN-13 barcode in img. The
+// barcode may be horizontal or vertical, either way up.
+func DecodeImage(img image.Image) (string, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	votes := make(map[string]int)
+	line := make([]uint8, 0, max(w, h))
+	for i := 1; i <= scanLines; i++ {
+		y := b.Min.Y + h*i/(scanLines+1)
+		line = line[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			line = append(line, luminance(img, x, y))
+		}
+		scan(line, votes)
+
+		x := b.Min.X + w*i/(scanLines+1)
+		line = line[:0]
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			line = append(line, luminance(img, x, y))
+		}
+		scan(line, votes)
+	}
+
+	best, count := "", 0
+	for code, n := range votes {
+		if n > count || n == count && code < best {
+			best, count = code, n
+		}
+	}
+	// A check digit lets one misreading in ten through, but a real barcode
+	// is crossed by many scan lines.
+	if count < minVotes {
+		return "", ErrNotFound
+	}
+	return best, nil
+}
+
+// luminance returns the grey level of a pixel.
+func luminance(img image.Image, x, y int) uint8 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	// ITU-R BT.601 luma, from 16-bit channels.
+	return uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+}
+
+// scan decodes a line of grey levels in both directions and records the
+// code found. Lines that do not decode as they are are tried again
+// smoothed, which helps with noisy photos of large barcodes but blurs the
+// bars of small ones together.
+func scan(line []uint8, votes map[string]int) {
+	for _, smooth := range []bool{false, true} {
+		runs, dark := binarize(line, smooth)
+		if code, ok := decodeRuns(runs, dark); ok {
+			votes[code]++
+			return
+		}
+
+		reversed := make([]int, len(runs))
+		for i, r := range runs {
+			reversed[len(runs)-1-i] = r
+		}
+		// The first run of the reversed line is dark if the last run was.
+		lastDark := dark == (len(runs)%2 == 1)
+		if code, ok := decodeRuns(reversed, lastDark); ok {
+			votes[code]++
+			return
+		}
+	}
+}
+
+// binarize splits a line into alternating runs of dark and light pixels,
+// comparing each pixel with the mean of its neighbourhood so uneven
+// lighting across a photo does not matter. firstDark tells which colour
+// the first run is.
+func binarize(line []uint8, smooth bool) (runs []int, firstDark bool) {
+	n := len(line)
+	if n == 0 {
+		return nil, false
+	}
+
+	values := make([]int, n)
+	for i := range line {
+		values[i] = 4 * int(line[i])
+		if smooth {
+			values[i] = 2*int(line[i]) + int(line[max(0, i-1)]) + int(line[min(n-1, i+1)])
+		}
+	}
+	sums := make([]int, n+1)
+	for i, v := range values {
+		sums[i+1] = sums[i] + v
+	}
+	radius := max(8, n/16)
+
+	isDark := func(i int) bool {
+		lo, hi := max(0, i-radius), min(n, i+radius+1)
+		mean := (sums[hi] - sums[lo]) / (hi - lo)
+		return values[i] < mean
+	}
+
+	firstDark = isDark(0)
+	current, length := firstDark, 0
+	for i := 0; i < n; i++ {
+		if d := isDark(i); d != current {
+			runs = append(runs, length)
+			current, length = d, 0
+		}
+		length++
+	}
+	runs = append(runs, length)
+	return runs, firstDark
+}
+
+// decodeRuns looks for an EAN-13 symbol in runs of alternating colour.
+func decodeRuns(runs []int, firstDark bool) (string, bool) {
+	const symbolRuns = 59
+	for start := 0; start+symbolRuns <= len(runs); start++ {
+		// Symbols start with a dark bar.
+		if (start%2 == 0) != firstDark {
+			continue
+		}
+		if code, ok := decodeSymbol(runs[start : start+symbolRuns]); ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// decodeSymbol decodes the 59 runs of an EAN-13 symbol, which starts with
+// the dark bar of the start guard.
+func decodeSymbol(runs []int) (string, bool) {
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+	module := float64(total) / 95
+	if module < 1 {
+		return "", false
+	}
+
+	// Guards are single-module bars and spaces.
+	for _, i := range []int{0, 1, 2, 27, 28, 29, 30, 31, 56, 57, 58} {
+		if m := float64(runs[i]) / module; m < 0.4 || m > 1.8 {
+			return "", false
+		}
+	}
+
+	digits := make([]byte, 13)
+	parity := 0
+	for d := 0; d < 6; d++ {
+		digit, even, ok := matchDigit(runs[3+d*4:7+d*4], true)
+		if !ok {
+			return "", false
+		}
+		digits[d+1] = byte('0' + digit)
+		parity <<= 1
+		if even {
+			parity |= 1
+		}
+	}
+	for d := 0; d < 6; d++ {
+		digit, _, ok := matchDigit(runs[32+d*4:36+d*4], false)
+		if !ok {
+			return "", false
+		}
+		digits[d+7] = byte('0' + digit)
+	}
+
+	first := -1
+	for digit, p := range firstDigitParity {
+		if p == parity {
+			first = digit
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	digits[0] = byte('0' + first)
+
+	if !validCheckDigit(digits) {
+		return "", false
+	}
+	return string(digits), true
+}
+
+// digitWidths are the module widths of the four runs of each digit in the
+// L code. The R code has the same widths with colours swapped, and the G
+// code has them reversed.
+var digitWidths = [10][4]float64{
+	{3, 2, 1, 1},
+	{2, 2, 2, 1},
+	{2, 1, 2, 2},
+	{1, 4, 1, 1},
+	{1, 1, 3, 2},
+	{1, 2, 3, 1},
+	{1, 1, 1, 4},
+	{1, 3, 1, 2},
+	{1, 2, 1, 3},
+	{3, 1, 1, 2},
+}
+
+// firstDigitParity encodes the first digit in which of the left-hand digits
+// use the G code (1 bits, first digit highest).
+var firstDigitParity = [10]int{
+	0x00, 0x0b, 0x0d, 0x0e, 0x13, 0x19, 0x1c, 0x15, 0x16, 0x1a,
+}
+
+// matchDigit finds the digit whose pattern the four runs are closest to.
+// Left-hand digits may use the L or G code; even reports G.
+func matchDigit(runs []int, left bool) (digit int, even bool, ok bool) {
+	total := float64(runs[0] + runs[1] + runs[2] + runs[3])
+	var widths [4]float64
+	for i, r := range runs {
+		widths[i] = float64(r) * 7 / total
+	}
+
+	best := maxDistance
+	for d, pattern := range digitWidths {
+		if dist := distance(widths, pattern, false); dist < best {
+			digit, even, ok, best = d, false, true, dist
+		}
+		if left {
+			if dist := distance(widths, pattern, true); dist < best {
+				digit, even, ok, best = d, true, true, dist
+			}
+		}
+	}
+	return digit, even, ok
+}
+
+func distance(widths, pattern [4]float64, reversed bool) float64 {
+	sum := 0.0
+	for i := range widths {
+		p := pattern[i]
+		if reversed {
+			p = pattern[3-i]
+		}
+		d := widths[i] - p
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum
+}
+
+// validCheckDigit applies the EAN-13 checksum: alternating weights 1 and 3
+// over all thirteen digits sum to a multiple of ten.
+func validCheckDigit(digits []byte) bool {
+	sum := 0
+	for i, c := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,137 @@
+package barcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// modulePixels is how wide each module of the rendered barcodes is.
+const modulePixels = 3
+
+// quietModules is the blank margin on each side of a rendered barcode.
+const quietModules = 11
+
+// modules returns the 95 modules of the EAN-13 symbol for code, true for
+// dark.
+func modules(code string) []bool {
+	var m []bool
+	runs := func(widths [4]float64, dark bool) {
+		for _, w := range widths {
+			for i := 0; i < int(w); i++ {
+				m = append(m, dark)
+			}
+			dark = !dark
+		}
+	}
+	guard := func(bars ...bool) { m = append(m, bars...) }
+
+	parity := firstDigitParity[code[0]-'0']
+	guard(true, false, true)
+	for d := 1; d <= 6; d++ {
+		widths := digitWidths[code[d]-'0']
+		if parity&(1<<(6-d)) != 0 {
+			widths = [4]float64{widths[3], widths[2], widths[1], widths[0]}
+		}
+		runs(widths, false)
+	}
+	guard(false, true, false, true, false)
+	for d := 7; d <= 12; d++ {
+		runs(digitWidths[code[d]-'0'], true)
+	}
+	guard(true, false, true)
+	return m
+}
+
+// render draws code as a horizontal barcode on a white background.
+func render(code string) *image.Gray {
+	m := modules(code)
+	width := (len(m) + 2*quietModules) * modulePixels
+	img := image.NewGray(image.Rect(0, 0, width, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < width; x++ {
+			img.Pix[img.PixOffset(x, y)] = 255
+			if i := x/modulePixels - quietModules; i >= 0 && i < len(m) && m[i] {
+				img.Pix[img.PixOffset(x, y)] = 0
+			}
+		}
+	}
+	return img
+}
+
+// transform returns a copy of img with each pixel moved by f.
+func transform(img *image.Gray, size image.Point, f func(x, y int) (int, int)) *image.Gray {
+	out := image.NewGray(image.Rectangle{Max: size})
+	b := img.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			tx, ty := f(x, y)
+			out.Pix[out.PixOffset(tx, ty)] = img.Pix[img.PixOffset(x, y)]
+		}
+	}
+	return out
+}
+
+func TestDecodeImage(t *testing.T) {
+	const code = "9780441013593"
+	img := render(code)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	noisy := render(code)
+	r := rand.New(rand.NewSource(1))
+	for i, v := range noisy.Pix {
+		n := int(v) + r.Intn(121) - 60
+		noisy.Pix[i] = uint8(min(255, max(0, n)))
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+		err  error
+	}{
+		{name: "horizontal", img: img, want: code},
+		{name: "vertical", img: transform(img, image.Pt(h, w), func(x, y int) (int, int) { return y, w - 1 - x }), want: code},
+		{name: "upside down", img: transform(img, image.Pt(w, h), func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }), want: code},
+		{name: "noise", img: noisy, want: code},
+		{name: "blank", img: blank, err: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeImage(tt.img)
+			if got != tt.want || err != tt.err {
+				t.Errorf("DecodeImage = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, render("9780547928227")); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := Decode(bytes.NewReader(buf.Bytes())); code != "9780547928227" || err != nil {
+		t.Errorf("Decode = %q, %v", code, err)
+	}
+
+	// The header claims 100000x100000 pixels; the IHDR chunk follows the
+	// 8 byte signature and its CRC covers the chunk type and data.
+	bomb := buf.Bytes()
+	binary.BigEndian.PutUint32(bomb[16:], 100_000)
+	binary.BigEndian.PutUint32(bomb[20:], 100_000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, err := Decode(bytes.NewReader(bomb)); err != ErrTooLarge {
+		t.Errorf("Decode of a huge image = %v, want ErrTooLarge", err)
+	}
+}
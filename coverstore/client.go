@@ -0,0 +1,63 @@
+package coverstore
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup"
+)
+
+// fetchTimeout bounds a whole cover download. Covers are fetched while a
+// book is being saved, so a slow host must not hold the save up for long.
+const fetchTimeout = 5 * time.Second
+
+// NewClient returns the client a Store downloads covers with. It gives up
+// after five seconds without retrying, reads at most MaxSize bytes and only
+// connects to public addresses: cover URLs come from users and imports, and
+// must not reach services on the shelf's own machine or network. Addresses
+// are checked as they are dialled, after DNS resolution and on every
+// redirect, so a public name pointing inside is refused too.
+func NewClient() *lookup.Client {
+	dialer := &net.Dialer{Timeout: fetchTimeout, Control: dialPublic}
+	return &lookup.Client{
+		HTTP: &http.Client{
+			Timeout: fetchTimeout,
+			Transport: &http.Transport{
+				// No proxy: the dialer could only check the proxy's address.
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: fetchTimeout,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		UserAgent: lookup.DefaultUserAgent,
+		MaxBody:   MaxSize,
+	}
+}
+
+// dialPublic refuses connections to addresses that are not public.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addrPort.Addr()) {
+		return fmt.Errorf("cover host %s is not a public address", addrPort.Addr())
+	}
+	return nil
+}
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
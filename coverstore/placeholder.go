@@ -0,0 +1,288 @@
+package coverstore
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Placeholder is a generated cover for a book without one: the title and
+// author on a background whose color is derived from the genre, so books of
+// a genre look alike on the shelf. The same book always gets the same
+// cover.
+type Placeholder struct {
+	Title  string
+	Author string
+	Genre  string
+}
+
+// placeholderColors are the backgrounds genres are spread across. Books
+// without a genre get neutralColor.
+var placeholderColors = []color.RGBA{
+	{0x3b, 0x5b, 0x92, 0xff}, // blue
+	{0x2f, 0x7d, 0x6d, 0xff}, // teal
+	{0x8e, 0x3b, 0x46, 0xff}, // burgundy
+	{0x6b, 0x4c, 0x9a, 0xff}, // purple
+	{0xa0, 0x5a, 0x1c, 0xff}, // rust
+	{0x4a, 0x7c, 0x2f, 0xff}, // green
+	{0x2d, 0x6e, 0x8e, 0xff}, // steel
+	{0x7a, 0x5c, 0x3e, 0xff}, // brown
+	{0x3f, 0x4f, 0x7a, 0xff}, // indigo
+	{0x9c, 0x3d, 0x6e, 0xff}, // plum
+	{0x35, 0x65, 0x4d, 0xff}, // forest
+	{0x8a, 0x6d, 0x1f, 0xff}, // ochre
+}
+
+var neutralColor = color.RGBA{0x5c, 0x6b, 0x73, 0xff}
+
+// PlaceholderURL returns the URL a placeholder cover is served at. format is
+// "svg" or "png"; PNG is for places that do not take SVG, such as link
+// previews.
+func PlaceholderURL(title, author, genre, format string) string {
+	query := url.Values{}
+	query.Set("title", title)
+	if author != "" {
+		query.Set("author", author)
+	}
+	if genre != "" {
+		query.Set("genre", genre)
+	}
+	return Prefix + "placeholder." + format + "?" + query.Encode()
+}
+
+// IsPlaceholder reports whether cover is a placeholder image rather than a
+// real cover: one of ours, or one from via.placeholder.com, which the shelf
+// used to link to.
+func IsPlaceholder(cover string) bool {
+	return strings.HasPrefix(cover, Prefix+"placeholder.") || strings.Contains(cover, "via.placeholder.com")
+}
+
+// Color is the background of the placeholder.
+func (p Placeholder) Color() color.RGBA {
+	genre := strings.ToLower(strings.TrimSpace(p.Genre))
+	if genre == "" {
+		return neutralColor
+	}
+	h := fnv.New32a()
+	h.Write([]byte(genre))
+	return placeholderColors[h.Sum32()%uint32(len(placeholderColors))]
+}
+
+const (
+	placeholderWidth  = 320
+	placeholderHeight = 480
+	spineWidth        = 20
+	margin            = 24
+
+	titleWrap  = 12 // characters per title line
+	authorWrap = 22
+	maxTitle   = 5 // lines
+	maxAuthor  = 2
+)
+
+func (p Placeholder) lines() (title, author []string) {
+	t := strings.TrimSpace(p.Title)
+	if t == "" {
+		t = "Untitled"
+	}
+	return wrap(t, titleWrap, maxTitle), wrap(strings.TrimSpace(p.Author), authorWrap, maxAuthor)
+}
+
+// SVG renders the placeholder as a 2:3 SVG image.
+func (p Placeholder) SVG() []byte {
+	title, author := p.lines()
+	bg := p.Color()
+	titleSize := 36
+	if longest(title) > 10 {
+		titleSize = 30
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d" role="img" aria-label="%s">`,
+		placeholderWidth, placeholderHeight, html.EscapeString(p.label()))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(bg))
+	fmt.Fprintf(&b, `<rect width="%d" height="100%%" fill="%s"/>`, spineWidth, hexColor(shade(bg, 0.7)))
+
+	center := spineWidth + (placeholderWidth-spineWidth)/2
+	b.WriteString(`<g fill="#ffffff" text-anchor="middle" font-family="Georgia, 'Times New Roman', serif">`)
+	y := 120
+	for _, line := range title {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="%d" font-weight="bold">%s</text>`, center, y, titleSize, html.EscapeString(line))
+		y += titleSize * 6 / 5
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="80" height="2" opacity="0.6"/>`, center-40, y)
+	y += 40
+	for _, line := range author {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="20" font-style="italic" opacity="0.9">%s</text>`, center, y, html.EscapeString(line))
+		y += 26
+	}
+	b.WriteString(`</g></svg>`)
+	return b.Bytes()
+}
+
+// PNG renders the placeholder as a 320x480 PNG image, drawing the text with
+// a built-in bitmap font.
+func (p Placeholder) PNG() ([]byte, error) {
+	title, author := p.lines()
+	for i := range title {
+		title[i] = glyphText(title[i])
+	}
+	for i := range author {
+		author[i] = glyphText(author[i])
+	}
+
+	bg := p.Color()
+	img := image.NewRGBA(image.Rect(0, 0, placeholderWidth, placeholderHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, spineWidth, placeholderHeight), &image.Uniform{C: shade(bg, 0.7)}, image.Point{}, draw.Src)
+
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	center := spineWidth + (placeholderWidth-spineWidth)/2
+	scale := 4
+	if longest(title)*6*scale > placeholderWidth-spineWidth-2*margin {
+		scale = 3
+	}
+	y := 96
+	for _, line := range title {
+		drawText(img, line, center, y, scale, white)
+		y += 10 * scale
+	}
+	y += 8
+	draw.Draw(img, image.Rect(center-40, y, center+40, y+2), &image.Uniform{C: shade(white, 0.8)}, image.Point{}, draw.Src)
+	y += 24
+	for _, line := range author {
+		drawText(img, line, center, y, 2, shade(white, 0.9))
+		y += 20
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p Placeholder) label() string {
+	if p.Author == "" {
+		return p.Title
+	}
+	return p.Title + " by " + p.Author
+}
+
+// servePlaceholder renders the placeholder described by the request's
+// title, author and genre parameters.
+func servePlaceholder(w http.ResponseWriter, req *http.Request, format string) {
+	query := req.URL.Query()
+	p := Placeholder{Title: query.Get("title"), Author: query.Get("author"), Genre: query.Get("genre")}
+
+	var data []byte
+	switch format {
+	case "svg":
+		data = p.SVG()
+		w.Header().Set("Content-Type", "image/svg+xml")
+	case "png":
+		var err error
+		if data, err = p.PNG(); err != nil {
+			http.Error(w, "Failed to render cover", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	// Rendering is deterministic, but may change between releases.
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	w.Write(data)
+}
+
+// drawText draws upper-case text centered on x with its top at y, each font
+// pixel scale pixels square.
+func drawText(img *image.RGBA, text string, x, y, scale int, c color.Color) {
+	runes := []rune(text)
+	advance := 6 * scale
+	left := x - (len(runes)*advance-scale)/2
+	src := &image.Uniform{C: c}
+	for i, r := range runes {
+		glyph, ok := glyphs[r]
+		if !ok {
+			continue
+		}
+		gx := left + i*advance
+		for row, bits := range glyph {
+			for col, bit := range bits {
+				if bit == '#' {
+					px := gx + col*scale
+					py := y + row*scale
+					draw.Draw(img, image.Rect(px, py, px+scale, py+scale), src, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+}
+
+// wrap breaks s into lines of at most width characters at spaces, breaking
+// longer words, and ends the text with an ellipsis after max lines.
+func wrap(s string, width, max int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			r := []rune(word)
+			lines = append(lines, string(r[:width]))
+			word = string(r[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > max {
+		lines = lines[:max]
+		last := []rune(lines[max-1])
+		if len(last) > width-1 {
+			last = last[:width-1]
+		}
+		lines[max-1] = strings.TrimSpace(string(last)) + "…"
+	}
+	return lines
+}
+
+func longest(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		if l := len([]rune(line)); l > n {
+			n = l
+		}
+	}
+	return n
+}
+
+func shade(c color.RGBA, f float64) color.RGBA {
+	return color.RGBA{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f), c.A}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,348 @@
+// Package coverstore keeps book cover images on the shelf's own disk.
+//
+// Covers are stored by content: a cover's file name is the SHA-256 of its
+// bytes, so the same image downloaded twice is stored once and a stored file
+// never changes, which lets it be cached by browsers for good. Every cover
+// also gets JPEG thumbnails in a few widths, named after the original with
+// the size appended:
+//
+//	covers/<sha256>.png
+//	covers/<sha256>-small.jpg
+//	covers/<sha256>-medium.jpg
+//	covers/<sha256>-large.jpg
+package coverstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup"
+)
+
+// Size is a thumbnail width.
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Sizes are the thumbnails generated for every cover.
+var Sizes = []Size{
+	{Name: "small", Width: 96},
+	{Name: "medium", Width: 240},
+	{Name: "large", Width: 480},
+}
+
+// MaxSize is the largest cover Fetch downloads.
+const MaxSize = 10 << 20
+
+// Prefix is the path covers are served under.
+const Prefix = "/covers/"
+
+var errNotImage = errors.New("cover is not a JPEG, PNG, GIF or WebP image")
+
+var namePattern = regexp.MustCompile(`^([0-9a-f]{64})(?:-([a-z]+))?(\.[a-z]+)$`)
+
+// Store is a directory of covers and their thumbnails.
+type Store struct {
+	Dir string
+	// Client downloads covers for Fetch; see NewClient. Its MaxBody is
+	// always capped to MaxSize.
+	Client *lookup.Client
+	// Fallback, when set, returns the URL the cover with the given hash was
+	// downloaded from. Requests for covers missing from Dir are redirected
+	// there rather than failing.
+	Fallback func(hash string) string
+}
+
+// NewStore returns a store in dir that downloads with NewClient.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, Client: NewClient()}
+}
+
+// IsLocal reports whether cover is a URL served by a Store.
+func IsLocal(cover string) bool {
+	return strings.HasPrefix(cover, Prefix)
+}
+
+// IsRemote reports whether cover is an http or https URL the store can
+// download.
+func IsRemote(cover string) bool {
+	return strings.HasPrefix(cover, "http://") || strings.HasPrefix(cover, "https://")
+}
+
+// IsData reports whether cover is an inline data: URL, which is how covers
+// are handed out before the book they belong to is saved.
+func IsData(cover string) bool {
+	return strings.HasPrefix(cover, "data:")
+}
+
+// DataURL returns an image as a base64 data: URL, or "" when data is not an
+// image the store accepts.
+func DataURL(data []byte) string {
+	if imageExtension(data) == "" {
+		return ""
+	}
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// Thumbnail returns the URL of the named thumbnail of a stored cover. Other
+// covers are returned unchanged.
+func Thumbnail(cover, size string) string {
+	if !IsLocal(cover) {
+		return cover
+	}
+	m := namePattern.FindStringSubmatch(path.Base(cover))
+	if m == nil || m[2] != "" {
+		return cover
+	}
+	return Prefix + m[1] + "-" + size + ".jpg"
+}
+
+// Put stores an image, generates its thumbnails and returns the URL it is
+// served at. The file extension comes from the data, and anything that is
+// not a JPEG, PNG, GIF or WebP image is refused, so the store never serves
+// other files. Thumbnails are skipped for formats that cannot be decoded,
+// which are then served at full size. Covers of more than MaxPixels are
+// refused with ErrTooLarge.
+func (s *Store) Put(data []byte) (string, error) {
+	ext := imageExtension(data)
+	if ext == "" {
+		return "", errNotImage
+	}
+	if w, h, ok := dimensions(data); ok && int64(w)*int64(h) > MaxPixels {
+		return "", ErrTooLarge
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	name := hash + ext
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(s.Dir, name)
+	if !fileExists(file) {
+		if err := writeFile(file, data); err != nil {
+			return "", err
+		}
+	}
+	if err := s.thumbnails(hash, data); err != nil && err != errUndecodable {
+		return "", err
+	}
+	return Prefix + name, nil
+}
+
+// Fetch downloads the cover at url into the store and returns its local URL.
+// Only http and https URLs are fetched, and no more than MaxSize bytes are
+// read.
+func (s *Store) Fetch(ctx context.Context, url string) (string, error) {
+	if !IsRemote(url) {
+		return "", fmt.Errorf("%s is not an http or https URL", url)
+	}
+	client := s.Client
+	if client == nil {
+		client = NewClient()
+	}
+	limited := *client
+	limited.MaxBody = MaxSize
+	data, err := limited.Get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	if imageExtension(data) == "" {
+		return "", fmt.Errorf("%s is not an image", url)
+	}
+	if w, h, ok := dimensions(data); ok && (w < minWidth || h < minWidth) {
+		// Catalogues answer unknown covers with a tiny blank image.
+		return "", fmt.Errorf("%s is a %dx%d placeholder", url, w, h)
+	}
+	return s.Put(data)
+}
+
+// PutData stores the image in a base64 data: URL and returns its local URL.
+func (s *Store) PutData(url string) (string, error) {
+	meta, encoded, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !IsData(url) || !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", errors.New("cover is not a base64 data: URL")
+	}
+	if base64.StdEncoding.DecodedLen(len(encoded)) > MaxSize {
+		return "", fmt.Errorf("cover is larger than %d bytes", MaxSize)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	return s.Put(data)
+}
+
+// Thumbnails generates any missing thumbnails of a stored cover.
+func (s *Store) Thumbnails(cover string) error {
+	m := namePattern.FindStringSubmatch(path.Base(cover))
+	if m == nil || m[2] != "" {
+		return fmt.Errorf("%s is not a stored cover", cover)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, m[1]+m[3]))
+	if err != nil {
+		return err
+	}
+	return s.thumbnails(m[1], data)
+}
+
+// ServeHTTP serves covers and thumbnails by file name, with the path
+// prefix stripped. Stored files never change, so responses may be cached
+// for a year. A missing thumbnail is generated on demand; a missing cover
+// is redirected to Fallback's URL. placeholder.svg and placeholder.png
+// render a Placeholder from the title, author and genre parameters.
+func (s *Store) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/placeholder.svg":
+		servePlaceholder(w, req, "svg")
+		return
+	case "/placeholder.png":
+		servePlaceholder(w, req, "png")
+		return
+	}
+
+	m := namePattern.FindStringSubmatch(path.Base(req.URL.Path))
+	if m == nil || path.Dir(req.URL.Path) != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	hash, size := m[1], m[2]
+	if size != "" && (!validSize(size) || m[3] != ".jpg") {
+		http.NotFound(w, req)
+		return
+	}
+
+	file := filepath.Join(s.Dir, m[0])
+	if size != "" && !fileExists(file) {
+		file = s.thumbnailOnDemand(hash, size)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if source := s.fallback(hash); source != "" {
+			w.Header().Set("Cache-Control", "no-cache")
+			http.Redirect(w, req, source, http.StatusFound)
+			return
+		}
+		http.NotFound(w, req)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read cover", 500)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+}
+
+// thumbnailOnDemand returns the file to serve for a thumbnail that has not
+// been generated: the new thumbnail, the original when it cannot be
+// decoded, or "" when the original is missing too.
+func (s *Store) thumbnailOnDemand(hash, size string) string {
+	original := s.original(hash)
+	if original == "" {
+		return ""
+	}
+	data, err := os.ReadFile(original)
+	if err != nil {
+		return ""
+	}
+	if err := s.thumbnails(hash, data); err != nil {
+		return original
+	}
+	return filepath.Join(s.Dir, hash+"-"+size+".jpg")
+}
+
+// Files returns the paths on disk of a stored cover and of the thumbnails
+// generated so far, or nil for covers the store does not hold.
+func (s *Store) Files(cover string) []string {
+	m := namePattern.FindStringSubmatch(path.Base(cover))
+	if !IsLocal(cover) || m == nil || m[2] != "" {
+		return nil
+	}
+	original := s.original(m[1])
+	if original == "" {
+		return nil
+	}
+	files := []string{original}
+	for _, size := range Sizes {
+		if name := filepath.Join(s.Dir, m[1]+"-"+size.Name+".jpg"); fileExists(name) {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// original returns the path of the cover with the given hash, or "".
+func (s *Store) original(hash string) string {
+	matches, _ := filepath.Glob(filepath.Join(s.Dir, hash+".*"))
+	for _, match := range matches {
+		if namePattern.MatchString(filepath.Base(match)) {
+			return match
+		}
+	}
+	return ""
+}
+
+func (s *Store) fallback(hash string) string {
+	if s.Fallback == nil {
+		return ""
+	}
+	return s.Fallback(hash)
+}
+
+func validSize(name string) bool {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// imageExtension returns the file extension for image data, or "" when it
+// is not an image.
+func imageExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// writeFile writes data through a temporary file so a half-written cover is
+// never served.
+func writeFile(name string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", name, time.Now().UnixNano())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,124 @@
+package coverstore
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"path/filepath"
+)
+
+// minWidth is the smallest cover Fetch accepts, in both dimensions.
+const minWidth = 10
+
+// MaxPixels is the largest cover the store accepts. A small compressed
+// file can claim enormous dimensions, so they are checked before any pixels
+// are decoded.
+const MaxPixels = 40_000_000
+
+// ErrTooLarge is returned for covers of more than MaxPixels.
+var ErrTooLarge = errors.New("cover is larger than 40 megapixels")
+
+var errUndecodable = errors.New("cover format cannot be decoded")
+
+// dimensions returns the size of an image without decoding its pixels.
+func dimensions(data []byte) (width, height int, ok bool) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// thumbnails writes a JPEG thumbnail of data in every size not already on
+// disk. Covers of more than MaxPixels are refused with ErrTooLarge before
+// they are decoded.
+func (s *Store) thumbnails(hash string, data []byte) error {
+	var src *image.RGBA
+	for _, size := range Sizes {
+		name := filepath.Join(s.Dir, hash+"-"+size.Name+".jpg")
+		if fileExists(name) {
+			continue
+		}
+		if src == nil {
+			if w, h, ok := dimensions(data); ok && int64(w)*int64(h) > MaxPixels {
+				return ErrTooLarge
+			}
+			decoded, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return errUndecodable
+			}
+			src = flatten(decoded)
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(src, size.Width), &jpeg.Options{Quality: 85}); err != nil {
+			return err
+		}
+		if err := writeFile(name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatten draws img onto a white background, since JPEG has no
+// transparency.
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// resize scales src down to width, keeping its aspect ratio, by averaging
+// the source pixels each target pixel covers. Images narrower than width
+// are left at their size.
+func resize(src *image.RGBA, width int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= width {
+		return src
+	}
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
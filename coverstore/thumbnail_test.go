@@ -0,0 +1,58 @@
+package coverstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pngOfSize returns a small PNG whose header claims width by height pixels.
+func pngOfSize(t *testing.T, width, height uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 60))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// The IHDR chunk follows the 8 byte signature: length, type, then the
+	// width and height, and its CRC covers the type and data.
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func TestPutRefusesHugeCovers(t *testing.T) {
+	store := NewStore(t.TempDir())
+	bomb := pngOfSize(t, 100_000, 100_000)
+
+	if _, err := store.Put(bomb); err != ErrTooLarge {
+		t.Errorf("Put = %v, want ErrTooLarge", err)
+	}
+	if entries, _ := os.ReadDir(store.Dir); len(entries) != 0 {
+		t.Errorf("store holds %d files after refusing a cover", len(entries))
+	}
+
+	// A cover stored before the limit is not decoded either.
+	if err := store.thumbnails("bomb", bomb); err != ErrTooLarge {
+		t.Errorf("thumbnails = %v, want ErrTooLarge", err)
+	}
+
+	cover, err := store.Put(pngOfSize(t, 40, 60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := store.Files(cover); len(files) != 1+len(Sizes) {
+		t.Errorf("Files = %v, want the cover and its thumbnails", files)
+	}
+	for _, size := range Sizes {
+		if _, err := os.Stat(filepath.Join(store.Dir, filepath.Base(Thumbnail(cover, size.Name)))); err != nil {
+			t.Error(err)
+		}
+	}
+}
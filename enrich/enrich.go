@@ -0,0 +1,196 @@
+// Package enrich fills in missing metadata for books already in the library.
+//
+// A Job walks the library in the background and looks up every book that is
+// missing its page count, genre, publisher or publication date, by ISBN or,
+// failing that, by title and author. What the catalogues know becomes a
+// Proposal. Proposals are suggestions: Apply only fills fields that are still
+// empty, so nothing the user entered is overwritten. The job saves its state
+// after every book, so a restart picks up where it left off.
+package enrich
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	"github.com/rahutchinson/book-list/lookup"
+	"github.com/rahutchinson/book-list/models"
+)
+
+// Proposal is the metadata found for one book, limited to the fields the
+// book was missing when it was looked up.
+type Proposal struct {
+	BookID string `json:"book_id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+	// Match is "isbn" when the book was found by its own ISBN and "search"
+	// when it was found by title and author.
+	Match string `json:"match"`
+	// ISBN is only proposed for books that have none.
+	ISBN      string    `json:"isbn,omitempty"`
+	Pages     int       `json:"pages,omitempty"`
+	Genre     string    `json:"genre,omitempty"`
+	Publisher string    `json:"publisher,omitempty"`
+	Published time.Time `json:"published"`
+	Sources   []string  `json:"sources,omitempty"`
+	Found     time.Time `json:"found"`
+}
+
+// Missing returns the names of the fields enrichment can fill that book
+// leaves empty.
+func Missing(book models.Book) []string {
+	var missing []string
+	if book.Pages == 0 {
+		missing = append(missing, "pages")
+	}
+	if book.Genre == "" {
+		missing = append(missing, "genre")
+	}
+	if book.Publisher == "" {
+		missing = append(missing, "publisher")
+	}
+	if book.Published.IsZero() {
+		missing = append(missing, "published")
+	}
+	return missing
+}
+
+// Apply fills the fields of book that are empty from p and returns the names
+// of the fields it filled.
+func Apply(book *models.Book, p Proposal) []string {
+	var filled []string
+	if book.ISBN == "" && p.ISBN != "" {
+		book.ISBN = p.ISBN
+		filled = append(filled, "isbn")
+	}
+	if book.Pages == 0 && p.Pages != 0 {
+		book.Pages = p.Pages
+		filled = append(filled, "pages")
+	}
+	if book.Genre == "" && p.Genre != "" {
+		book.Genre = p.Genre
+		filled = append(filled, "genre")
+	}
+	if book.Publisher == "" && p.Publisher != "" {
+		book.Publisher = p.Publisher
+		filled = append(filled, "publisher")
+	}
+	if book.Published.IsZero() && !p.Published.IsZero() {
+		book.Published = p.Published
+		filled = append(filled, "published")
+	}
+	return filled
+}
+
+// propose looks book up and returns what the catalogues can fill, or nil
+// when they know nothing new.
+func (j *Job) propose(ctx context.Context, book models.Book) (*Proposal, error) {
+	var result *lookup.Result
+	match := "isbn"
+	if code, err := isbn.Canonical(book.ISBN); err == nil {
+		if result, err = j.Lookup(ctx, code); err != nil {
+			return nil, err
+		}
+	}
+
+	if result == nil && j.Search != nil && book.Name != "" {
+		candidates, err := j.Search(ctx, book.Name, book.Author)
+		if err != nil {
+			return nil, err
+		}
+		if c := bestCandidate(book, candidates); c != nil {
+			if result, err = j.Lookup(ctx, c.ISBNs[0]); err != nil {
+				return nil, err
+			}
+			match = "search"
+		}
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	p := Proposal{
+		BookID:  book.ID,
+		Name:    book.Name,
+		Author:  book.Author,
+		Match:   match,
+		Sources: result.Sources,
+		Found:   time.Now(),
+	}
+	if book.ISBN == "" && match == "search" {
+		p.ISBN = result.ISBN
+	}
+	if book.Pages == 0 {
+		p.Pages = result.Pages
+	}
+	if book.Genre == "" {
+		p.Genre = result.Genre
+	}
+	if book.Publisher == "" {
+		p.Publisher = result.Publisher
+	}
+	if book.Published.IsZero() {
+		p.Published = result.Published
+	}
+
+	if p.Pages == 0 && p.Genre == "" && p.Publisher == "" && p.Published.IsZero() {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// bestCandidate returns the highest ranked search result that is plausibly
+// the same book: its title matches the book's, allowing for a subtitle on
+// either side, and its author shares a name with the book's. Results without
+// an ISBN cannot be looked up and are ignored.
+func bestCandidate(book models.Book, candidates []lookup.Candidate) *lookup.Candidate {
+	title := words(book.Name)
+	author := words(book.Author)
+	for i, c := range candidates {
+		if len(c.ISBNs) == 0 || !prefixMatch(title, words(c.Title)) {
+			continue
+		}
+		if len(author) > 0 && c.Author != "" && !shareWord(author, words(c.Author)) {
+			continue
+		}
+		return &candidates[i]
+	}
+	return nil
+}
+
+// prefixMatch reports whether the shorter of a and b starts the longer.
+func prefixMatch(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// shareWord reports whether a and b have a word longer than an initial in
+// common.
+func shareWord(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y && len(x) > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,153 @@
+package enrich
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup"
+	"github.com/rahutchinson/book-list/models"
+)
+
+func TestApply(t *testing.T) {
+	published := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+	mine := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	proposal := Proposal{ISBN: "9780441013593", Pages: 412, Genre: "Science Fiction", Publisher: "Ace", Published: published}
+
+	tests := []struct {
+		name       string
+		book       models.Book
+		want       models.Book
+		wantFilled []string
+	}{
+		{
+			name:       "empty fields are filled",
+			book:       models.Book{Name: "Dune"},
+			want:       models.Book{Name: "Dune", ISBN: "9780441013593", Pages: 412, Genre: "Science Fiction", Publisher: "Ace", Published: published},
+			wantFilled: []string{"isbn", "pages", "genre", "publisher", "published"},
+		},
+		{
+			name:       "fields the user filled are kept",
+			book:       models.Book{Name: "Dune", ISBN: "9780340960196", Pages: 600, Genre: "Classic", Publisher: "Hodder", Published: mine},
+			want:       models.Book{Name: "Dune", ISBN: "9780340960196", Pages: 600, Genre: "Classic", Publisher: "Hodder", Published: mine},
+			wantFilled: nil,
+		},
+		{
+			name:       "only the gaps are filled",
+			book:       models.Book{Name: "Dune", Genre: "Classic"},
+			want:       models.Book{Name: "Dune", ISBN: "9780441013593", Pages: 412, Genre: "Classic", Publisher: "Ace", Published: published},
+			wantFilled: []string{"isbn", "pages", "publisher", "published"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := tt.book
+			filled := Apply(&book, proposal)
+			if !reflect.DeepEqual(book, tt.want) {
+				t.Errorf("book = %+v, want %+v", book, tt.want)
+			}
+			if !reflect.DeepEqual(filled, tt.wantFilled) {
+				t.Errorf("filled = %v, want %v", filled, tt.wantFilled)
+			}
+		})
+	}
+}
+
+func TestBestCandidate(t *testing.T) {
+	book := models.Book{Name: "Dune", Author: "Frank Herbert"}
+	tests := []struct {
+		name       string
+		book       models.Book
+		candidates []lookup.Candidate
+		want       string
+	}{
+		{
+			name: "subtitle and ranking",
+			book: book,
+			candidates: []lookup.Candidate{
+				{Key: "/works/1", Title: "Children of Dune", Author: "Frank Herbert", ISBNs: []string{"9780441104024"}},
+				{Key: "/works/2", Title: "Dune: Deluxe Edition", Author: "Frank Herbert", ISBNs: []string{"9780593099322"}},
+			},
+			want: "/works/2",
+		},
+		{
+			name: "different author",
+			book: book,
+			candidates: []lookup.Candidate{
+				{Key: "/works/1", Title: "Dune", Author: "Kevin J. Anderson", ISBNs: []string{"9780000000002"}},
+				{Key: "/works/2", Title: "Dune", Author: "F. Herbert", ISBNs: []string{"9780441013593"}},
+			},
+			want: "/works/2",
+		},
+		{
+			name:       "without ISBNs nothing can be looked up",
+			book:       book,
+			candidates: []lookup.Candidate{{Key: "/works/1", Title: "Dune", Author: "Frank Herbert"}},
+		},
+		{
+			name:       "an initial is not a shared name",
+			book:       models.Book{Name: "Dune", Author: "F. Herbert"},
+			candidates: []lookup.Candidate{{Key: "/works/1", Title: "Dune", Author: "F. Scott", ISBNs: []string{"9780441013593"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if c := bestCandidate(tt.book, tt.candidates); c != nil {
+				got = c.Key
+			}
+			if got != tt.want {
+				t.Errorf("bestCandidate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProposeOnlyOffersMissingFields(t *testing.T) {
+	result := &lookup.Result{ISBN: "9780441013593", Title: "Dune", Pages: 412, Genre: "Science Fiction", Publisher: "Ace", Published: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC), Sources: []string{"fake"}}
+	j := &Job{
+		Lookup: func(ctx context.Context, code string) (*lookup.Result, error) { return result, nil },
+		Search: func(ctx context.Context, title, author string) ([]lookup.Candidate, error) {
+			return []lookup.Candidate{{Title: "Dune", Author: "Frank Herbert", ISBNs: []string{"9780441013593"}}}, nil
+		},
+	}
+
+	tests := []struct {
+		name string
+		book models.Book
+		want *Proposal
+	}{
+		{
+			name: "by ISBN",
+			book: models.Book{ID: "1", Name: "Dune", ISBN: "9780441013593", Pages: 600, Publisher: "Hodder"},
+			want: &Proposal{BookID: "1", Name: "Dune", Match: "isbn", Genre: "Science Fiction", Published: result.Published, Sources: []string{"fake"}},
+		},
+		{
+			name: "by search proposes the ISBN",
+			book: models.Book{ID: "2", Name: "Dune", Author: "Frank Herbert", Genre: "Classic"},
+			want: &Proposal{BookID: "2", Name: "Dune", Author: "Frank Herbert", Match: "search", ISBN: "9780441013593", Pages: 412, Publisher: "Ace", Published: result.Published, Sources: []string{"fake"}},
+		},
+		{
+			name: "nothing new",
+			book: models.Book{ID: "3", Name: "Dune", ISBN: "9780441013593", Pages: 600, Genre: "Classic", Publisher: "Hodder", Published: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := j.propose(context.Background(), tt.book)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != nil {
+				got.Found = time.Time{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("propose = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup"
+	"github.com/rahutchinson/book-list/models"
+)
+
+// Status is where a job is in its walk of the library.
+type Status string
+
+const (
+	Idle    Status = "idle"
+	Running Status = "running"
+	// Paused jobs were stopped, or gave up after repeated lookup errors,
+	// and continue where they left off when started again.
+	Paused Status = "paused"
+	Done   Status = "done"
+)
+
+// ErrRunning is returned when starting a job that is already running.
+var ErrRunning = errors.New("enrichment is already running")
+
+// maxErrors is how many lookups in a row may fail before the job pauses
+// itself rather than burn through the library while a catalogue is down.
+const maxErrors = 5
+
+// State is what a job saves between books.
+type State struct {
+	Status   Status    `json:"status"`
+	Started  time.Time `json:"started"`
+	Updated  time.Time `json:"updated"`
+	Finished time.Time `json:"finished"`
+	// Checked holds the IDs of the books already looked up. Books whose
+	// lookup failed are left out so they are tried again.
+	Checked   map[string]bool `json:"checked"`
+	NotFound  int             `json:"not_found"`
+	Errors    int             `json:"errors"`
+	LastError string          `json:"last_error,omitempty"`
+	Proposals []Proposal      `json:"proposals"`
+}
+
+// Progress reports how far a job has got.
+type Progress struct {
+	Status   Status    `json:"status"`
+	Started  time.Time `json:"started"`
+	Updated  time.Time `json:"updated"`
+	Finished time.Time `json:"finished"`
+	// Total counts the books the job has checked and those it still has
+	// to; Processed the first of those.
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+	Remaining int    `json:"remaining"`
+	Proposals int    `json:"proposals"`
+	NotFound  int    `json:"not_found"`
+	Errors    int    `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+	// Current is the title of the book being looked up.
+	Current string `json:"current,omitempty"`
+}
+
+// Job enriches the library in the background, one book every Interval.
+type Job struct {
+	// Path is the file the job's state is kept in.
+	Path  string
+	Books func() []models.Book
+	// Lookup finds a book by canonical ISBN, returning nil when no
+	// catalogue knows it.
+	Lookup func(ctx context.Context, isbn string) (*lookup.Result, error)
+	// Search, when set, finds books without a usable ISBN by title and
+	// author.
+	Search   func(ctx context.Context, title, author string) ([]lookup.Candidate, error)
+	Interval time.Duration
+
+	mu      sync.Mutex
+	state   State
+	current string
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// Load reads the state saved by an earlier run. A missing file is an idle
+// job.
+func (j *Job) Load() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := os.ReadFile(j.Path)
+	if os.IsNotExist(err) {
+		j.state = State{Status: Idle}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &j.state)
+}
+
+// Resume restarts a job that was running when the process last stopped.
+func (j *Job) Resume() error {
+	j.mu.Lock()
+	running := j.state.Status == Running
+	j.mu.Unlock()
+	if !running {
+		return nil
+	}
+	return j.Start(false)
+}
+
+// Start begins walking the library in the background. It continues from
+// where the last run stopped, checking only books not checked yet, unless
+// restart is set, which forgets earlier runs and their pending proposals.
+func (j *Job) Start(restart bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cancel != nil {
+		return ErrRunning
+	}
+	if restart || j.state.Checked == nil {
+		j.state = State{Checked: make(map[string]bool)}
+	}
+	if restart || j.state.Status != Paused && j.state.Status != Running {
+		j.state.Started = time.Now()
+	}
+	j.state.Status = Running
+	j.state.Finished = time.Time{}
+	j.state.LastError = ""
+	if err := j.save(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.stopped = make(chan struct{})
+	go j.run(ctx, j.stopped)
+	return nil
+}
+
+// Stop pauses a running job and waits for it to finish its current book.
+func (j *Job) Stop() error {
+	j.mu.Lock()
+	cancel, stopped := j.cancel, j.stopped
+	j.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-stopped
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// The run may have finished by itself while Stop waited, and a new one
+	// started; that one is not Stop's to pause.
+	if j.stopped != stopped {
+		return nil
+	}
+	j.cancel = nil
+	j.current = ""
+	if j.state.Status != Running {
+		return nil
+	}
+	j.state.Status = Paused
+	return j.save()
+}
+
+// Progress reports the job's status and counts.
+func (j *Job) Progress() Progress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	remaining := 0
+	if j.state.Status != Done && j.Books != nil {
+		for _, book := range j.Books() {
+			if !j.state.Checked[book.ID] && len(Missing(book)) > 0 {
+				remaining++
+			}
+		}
+	}
+	return Progress{
+		Status:    j.state.Status,
+		Started:   j.state.Started,
+		Updated:   j.state.Updated,
+		Finished:  j.state.Finished,
+		Total:     len(j.state.Checked) + remaining,
+		Processed: len(j.state.Checked),
+		Remaining: remaining,
+		Proposals: len(j.state.Proposals),
+		NotFound:  j.state.NotFound,
+		Errors:    j.state.Errors,
+		LastError: j.state.LastError,
+		Current:   j.current,
+	}
+}
+
+// Proposals returns the proposals not yet applied or dismissed.
+func (j *Job) Proposals() []Proposal {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Proposal{}, j.state.Proposals...)
+}
+
+// Remove drops the proposals for the given book IDs, once they have been
+// applied or dismissed. No IDs removes them all.
+func (j *Job) Remove(ids []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	kept := j.state.Proposals[:0]
+	for _, p := range j.state.Proposals {
+		if len(ids) > 0 && !remove[p.BookID] {
+			kept = append(kept, p)
+		}
+	}
+	j.state.Proposals = kept
+	return j.save()
+}
+
+func (j *Job) run(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	var last time.Time
+	failures := 0
+	for _, book := range j.Books() {
+		j.mu.Lock()
+		checked := j.state.Checked[book.ID]
+		j.mu.Unlock()
+		if checked || len(Missing(book)) == 0 {
+			continue
+		}
+
+		if wait := j.Interval - time.Since(last); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		last = time.Now()
+
+		j.mu.Lock()
+		j.current = book.Name
+		j.mu.Unlock()
+
+		proposal, err := j.propose(ctx, book)
+		if ctx.Err() != nil {
+			// Stopped mid-lookup: the book is checked again on resume.
+			return
+		}
+
+		j.mu.Lock()
+		j.current = ""
+		j.state.Updated = time.Now()
+		switch {
+		case err != nil:
+			failures++
+			j.state.Errors++
+			j.state.LastError = book.Name + ": " + err.Error()
+		case proposal == nil:
+			failures = 0
+			j.state.NotFound++
+			j.state.Checked[book.ID] = true
+		default:
+			failures = 0
+			j.state.Checked[book.ID] = true
+			j.addProposal(*proposal)
+		}
+		if failures >= maxErrors {
+			j.state.Status = Paused
+			j.release()
+		}
+		j.save()
+		paused := j.state.Status == Paused
+		j.mu.Unlock()
+		if paused {
+			return
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state.Status = Done
+	j.state.Finished = time.Now()
+	j.release()
+	j.save()
+}
+
+// release lets the job be started again once run has stopped by itself. The
+// caller holds j.mu.
+func (j *Job) release() {
+	if j.cancel != nil {
+		j.cancel()
+		j.cancel = nil
+	}
+}
+
+// addProposal records p, replacing an older proposal for the same book.
+func (j *Job) addProposal(p Proposal) {
+	for i, existing := range j.state.Proposals {
+		if existing.BookID == p.BookID {
+			j.state.Proposals[i] = p
+			return
+		}
+	}
+	j.state.Proposals = append(j.state.Proposals, p)
+}
+
+// save writes the state file. The caller holds j.mu.
+func (j *Job) save() error {
+	data, err := json.MarshalIndent(j.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.Path)
+}
@@ -0,0 +1,154 @@
+package enrich
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup"
+	"github.com/rahutchinson/book-list/models"
+)
+
+var library = []models.Book{
+	{ID: "1", Name: "Dune", ISBN: "9780441013593"},
+	{ID: "2", Name: "Emma", ISBN: "9780141439587"},
+	{ID: "3", Name: "Complete", ISBN: "9780547928227", Pages: 1, Genre: "x", Publisher: "x", Published: time.Now()},
+}
+
+func newTestJob(path string, interval time.Duration) *Job {
+	return &Job{
+		Path:  path,
+		Books: func() []models.Book { return library },
+		Lookup: func(ctx context.Context, code string) (*lookup.Result, error) {
+			return &lookup.Result{ISBN: code, Pages: 100}, nil
+		},
+		Interval: interval,
+	}
+}
+
+// waitFor polls the job until it reaches status.
+func waitFor(t *testing.T, j *Job, status Status) Progress {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if p := j.Progress(); p.Status == status && p.Current == "" {
+			return p
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job status = %s, want %s", j.Progress().Status, status)
+	return Progress{}
+}
+
+func TestJobStopAndResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enrich.json")
+
+	// The long interval holds the job after its first book.
+	j := newTestJob(path, time.Hour)
+	if err := j.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Start(false); err != ErrRunning {
+		t.Errorf("second Start = %v, want ErrRunning", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for j.Progress().Processed < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := j.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if p := j.Progress(); p.Status != Paused || p.Processed != 1 || p.Remaining != 1 || p.Proposals != 1 {
+		t.Fatalf("after Stop: %+v", p)
+	}
+
+	// A new process picks up the saved state and checks only the rest.
+	resumed := newTestJob(path, 0)
+	if err := resumed.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if p := resumed.Progress(); p.Status != Paused || p.Processed != 1 {
+		t.Fatalf("loaded: %+v", p)
+	}
+	if err := resumed.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	p := waitFor(t, resumed, Done)
+	if p.Processed != 2 || p.Remaining != 0 || p.Proposals != 2 {
+		t.Errorf("after resuming: %+v", p)
+	}
+	if proposals := resumed.Proposals(); proposals[0].BookID != "1" || proposals[1].BookID != "2" {
+		t.Errorf("proposals = %+v, want one for each book in order", proposals)
+	}
+}
+
+func TestJobResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enrich.json")
+
+	j := newTestJob(path, time.Hour)
+	j.Load()
+	if err := j.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	// The process exits without stopping the job, which is still running
+	// according to its state file.
+	restarted := newTestJob(path, 0)
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, restarted, Done)
+	j.Stop()
+
+	idle := newTestJob(filepath.Join(t.TempDir(), "enrich.json"), 0)
+	idle.Load()
+	if err := idle.Resume(); err != nil || idle.Progress().Status != Idle {
+		t.Errorf("Resume of an idle job = %v, status %s", err, idle.Progress().Status)
+	}
+}
+
+func TestStopLeavesANewRunAlone(t *testing.T) {
+	j := newTestJob(filepath.Join(t.TempDir(), "enrich.json"), time.Hour)
+	j.Load()
+
+	// An earlier run is finishing by itself when Stop catches it.
+	cancelled := make(chan struct{})
+	old := make(chan struct{})
+	var once sync.Once
+	j.cancel = func() { once.Do(func() { close(cancelled) }) }
+	j.stopped = old
+	j.state.Status = Running
+
+	done := make(chan error)
+	go func() { done <- j.Stop() }()
+	<-cancelled
+
+	// The old run releases the job and a new one starts before it exits.
+	j.mu.Lock()
+	j.release()
+	j.mu.Unlock()
+	if err := j.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	close(old)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	j.mu.Lock()
+	running := j.cancel != nil && j.state.Status == Running
+	j.mu.Unlock()
+	if !running {
+		t.Fatal("Stop paused the run started while it waited")
+	}
+	if err := j.Stop(); err != nil || j.Progress().Status != Paused {
+		t.Errorf("Stop = %v, status %s", err, j.Progress().Status)
+	}
+}
@@ -0,0 +1,112 @@
+// Package epub extracts metadata and the cover image from EPUB files.
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	opf "github.com/rahutchinson/book-list/opf"
+)
+
+// maxCoverSize bounds how much of a cover image is read into memory.
+const maxCoverSize = 10 << 20
+
+// File is the metadata of an EPUB.
+type File struct {
+	Package *opf.Package
+	// Cover holds the embedded cover image, if the package declares one.
+	Cover     []byte
+	CoverName string
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// Read opens an EPUB from r and parses its package document.
+func Read(r io.ReaderAt, size int64) (*File, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an EPUB: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	rootPath, err := findRootfile(files)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := files[rootPath].Open()
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := opf.Parse(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", rootPath, err)
+	}
+
+	epub := &File{Package: pkg}
+	if href := pkg.CoverHref(); href != "" {
+		// Manifest hrefs are relative to the package document.
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		name := path.Join(path.Dir(rootPath), href)
+		if f, ok := files[name]; ok {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			epub.Cover, err = io.ReadAll(io.LimitReader(rc, maxCoverSize))
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			epub.CoverName = path.Base(name)
+		}
+	}
+
+	return epub, nil
+}
+
+// findRootfile locates the package document via META-INF/container.xml.
+func findRootfile(files map[string]*zip.File) (string, error) {
+	f, ok := files["META-INF/container.xml"]
+	if !ok {
+		return "", errors.New("not an EPUB: missing META-INF/container.xml")
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var c container
+	if err := xml.NewDecoder(rc).Decode(&c); err != nil {
+		return "", fmt.Errorf("parsing container.xml: %w", err)
+	}
+
+	for _, root := range c.Rootfiles {
+		if root.MediaType == "application/oebps-package+xml" || strings.HasSuffix(root.FullPath, ".opf") {
+			if _, ok := files[root.FullPath]; ok {
+				return root.FullPath, nil
+			}
+		}
+	}
+	return "", errors.New("not an EPUB: no package document")
+}
@@ -0,0 +1,79 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+)
+
+// build returns an EPUB holding files.
+func build(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+const containerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+
+func TestRead(t *testing.T) {
+	opf, err := os.ReadFile("../opf/testdata/epub3.opf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := build(t, map[string]string{
+		"mimetype":                   "application/epub+zip",
+		"META-INF/container.xml":     containerXML,
+		"OEBPS/content.opf":          string(opf),
+		"OEBPS/images/cover art.png": "cover bytes",
+		"OEBPS/text.xhtml":           "<html/>",
+	})
+
+	f, err := Read(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book := f.Package.Book(); book.Name != "Dune & Other Stories" || book.ISBN != "9780441013593" {
+		t.Errorf("Book = %+v", book)
+	}
+	if string(f.Cover) != "cover bytes" || f.CoverName != "cover art.png" {
+		t.Errorf("cover = %q, %q", f.Cover, f.CoverName)
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "no container", files: map[string]string{"OEBPS/content.opf": "<package/>"}},
+		{name: "missing package", files: map[string]string{"META-INF/container.xml": containerXML}},
+		{name: "broken package", files: map[string]string{"META-INF/container.xml": containerXML, "OEBPS/content.opf": "<package"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := build(t, tt.files)
+			if _, err := Read(r, r.Size()); err == nil {
+				t.Error("Read succeeded")
+			}
+		})
+	}
+	if _, err := Read(bytes.NewReader([]byte("not a zip")), 9); err == nil {
+		t.Error("Read of a non-zip file succeeded")
+	}
+}
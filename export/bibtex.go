@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// BibTeX writes one @book entry per book.
+var BibTeX = Format{
+	Name:        "bibtex",
+	ContentType: "application/x-bibtex; charset=utf-8",
+	Extension:   ".bib",
+	write:       writeBibTeX,
+}
+
+func init() {
+	register(BibTeX)
+}
+
+func writeBibTeX(w io.Writer, books []models.Book, opts Options) error {
+	bw := bufio.NewWriter(w)
+	keys := citationKeys(books, opts.Library)
+
+	for i, book := range books {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "@book{%s,\n", keys[i])
+
+		names := authorNames(book.Author)
+		authors := make([]string, len(names))
+		for j, n := range names {
+			authors[j] = bibtexEscape(n.String())
+			if n.Given == "" {
+				// Braces keep BibTeX from splitting a literal name.
+				authors[j] = "{" + authors[j] + "}"
+			}
+		}
+		bibtexField(bw, "author", strings.Join(authors, " and "))
+		bibtexField(bw, "title", bibtexEscape(book.Name))
+		bibtexField(bw, "publisher", bibtexEscape(book.Publisher))
+		if !book.Published.IsZero() {
+			bibtexField(bw, "year", fmt.Sprint(book.Published.Year()))
+		}
+		bibtexField(bw, "isbn", book.ISBN)
+		bw.WriteString("}\n")
+	}
+
+	return bw.Flush()
+}
+
+func bibtexField(w *bufio.Writer, field, value string) {
+	if value != "" {
+		fmt.Fprintf(w, "  %s = {%s},\n", field, value)
+	}
+}
+
+var bibtexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"&", `\&`,
+	"%", `\%`,
+	"$", `\$`,
+	"#", `\#`,
+	"_", `\_`,
+	"~", `\textasciitilde{}`,
+	"^", `\textasciicircum{}`,
+)
+
+// bibtexEscape escapes the characters LaTeX treats specially.
+func bibtexEscape(s string) string {
+	return bibtexReplacer.Replace(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// name is an author split for citation formats. Names that cannot be split,
+// such as "Plato" or an organisation, only have Family set and are treated
+// as literal names.
+type name struct {
+	Family string
+	Given  string
+}
+
+func (n name) String() string {
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
+
+// authorNames splits an author field into individual names. Authors may be
+// joined with "and", "&" or ";", and each may be written "Given Family" or
+// "Family, Given".
+func authorNames(author string) []name {
+	author = strings.NewReplacer(" & ", ";", " and ", ";").Replace(author)
+
+	var names []name
+	for _, a := range parse.List(author, ";") {
+		if family, given, ok := strings.Cut(a, ","); ok {
+			names = append(names, name{Family: strings.TrimSpace(family), Given: strings.TrimSpace(given)})
+			continue
+		}
+		fields := strings.Fields(a)
+		if len(fields) == 1 {
+			names = append(names, name{Family: fields[0]})
+			continue
+		}
+		names = append(names, name{Family: fields[len(fields)-1], Given: strings.Join(fields[:len(fields)-1], " ")})
+	}
+	return names
+}
+
+// citationKeys returns a citation key for each book, in order. Keys are the
+// first author's family name, the publication year and the first significant
+// word of the title, e.g. "austen1813pride", so they stay the same between
+// exports. Books that would share a key get a letter suffix, assigned in ID
+// order over the whole library rather than the books being exported, so a
+// book keeps its key whichever selection it is exported with. Books missing
+// from library are ranked as if they were in it.
+func citationKeys(books, library []models.Book) []string {
+	byID := make(map[string]models.Book, len(library)+len(books))
+	for _, book := range library {
+		byID[book.ID] = book
+	}
+	for _, book := range books {
+		if _, ok := byID[book.ID]; !ok {
+			byID[book.ID] = book
+		}
+	}
+
+	byKey := make(map[string][]string)
+	for id, book := range byID {
+		key := citationKey(book)
+		byKey[key] = append(byKey[key], id)
+	}
+
+	keyOf := make(map[string]string, len(byID))
+	for key, ids := range byKey {
+		if len(ids) < 2 {
+			keyOf[ids[0]] = key
+			continue
+		}
+		sort.Strings(ids)
+		for n, id := range ids {
+			keyOf[id] = key + suffix(n)
+		}
+	}
+
+	keys := make([]string, len(books))
+	for i, book := range books {
+		keys[i] = keyOf[book.ID]
+	}
+	return keys
+}
+
+func citationKey(book models.Book) string {
+	author := "anon"
+	if names := authorNames(book.Author); len(names) > 0 {
+		if k := keyPart(names[0].Family); k != "" {
+			author = k
+		}
+	}
+
+	year := "nd"
+	if !book.Published.IsZero() {
+		year = fmt.Sprint(book.Published.Year())
+	}
+
+	var word string
+	for _, w := range strings.Fields(book.Name) {
+		w = keyPart(w)
+		if w != "" && !stopWords[w] {
+			word = w
+			break
+		}
+	}
+	return author + year + word
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "of": true, "on": true, "in": true,
+	"and": true, "to": true, "for": true,
+}
+
+// keyPart reduces s to lowercase ASCII letters and digits, folding common
+// accented letters.
+func keyPart(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := accents[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i",
+	'î': "i", 'ï': "i", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o",
+	'ö': "o", 'ø': "o", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y",
+	'ÿ': "y", 'ß': "ss", 'ł': "l", 'š': "s", 'ž': "z", 'č': "c", 'ř': "r",
+}
+
+// suffix returns "a" for 0, "b" for 1, ..., "aa" after "z".
+func suffix(n int) string {
+	s := string(rune('a' + n%26))
+	if n >= 26 {
+		s = suffix(n/26-1) + s
+	}
+	return s
+}
+
+// yearOnly reports whether a publication date only carries a year, which
+// is how imports store a bare year.
+func yearOnly(t time.Time) bool {
+	return t.Month() == time.January && t.Day() == 1
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+func TestCitationKeys(t *testing.T) {
+	year := func(y int) time.Time { return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC) }
+	pride := models.Book{ID: "1", Name: "Pride and Prejudice", Author: "Jane Austen", Published: year(1813)}
+	dune := models.Book{ID: "2", Name: "Dune", Author: "Frank Herbert", Published: year(1965)}
+	duneAgain := models.Book{ID: "3", Name: "Dune", Author: "Herbert, Frank", Published: year(1965)}
+	duneFirst := models.Book{ID: "0", Name: "Dune", Author: "Frank Herbert", Published: year(1965)}
+	library := []models.Book{pride, dune, duneAgain}
+
+	tests := []struct {
+		name    string
+		books   []models.Book
+		library []models.Book
+		want    []string
+	}{
+		{
+			name:  "family name, year and first significant word",
+			books: []models.Book{pride},
+			want:  []string{"austen1813pride"},
+		},
+		{
+			name:  "no author or year",
+			books: []models.Book{{ID: "9", Name: "The Anonymous Book"}},
+			want:  []string{"anonndanonymous"},
+		},
+		{
+			name:  "accents and several authors",
+			books: []models.Book{{ID: "9", Name: "Élan", Author: "Gabriel García Márquez & Someone Else", Published: year(1967)}},
+			want:  []string{"marquez1967elan"},
+		},
+		{
+			name:  "clashes get suffixes in ID order",
+			books: []models.Book{duneAgain, dune},
+			want:  []string{"herbert1965duneb", "herbert1965dunea"},
+		},
+		{
+			name:    "suffixes come from the whole library",
+			books:   []models.Book{duneAgain},
+			library: library,
+			want:    []string{"herbert1965duneb"},
+		},
+		{
+			name:    "no suffix without a clash in the library",
+			books:   []models.Book{pride},
+			library: library,
+			want:    []string{"austen1813pride"},
+		},
+		{
+			name:    "books outside the library are ranked with it",
+			books:   []models.Book{duneFirst, dune},
+			library: library,
+			want:    []string{"herbert1965dunea", "herbert1965duneb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citationKeys(tt.books, tt.library); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("citationKeys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	tests := map[int]string{0: "a", 1: "b", 25: "z", 26: "aa", 27: "ab", 51: "az", 52: "ba"}
+	for n, want := range tests {
+		if got := suffix(n); got != want {
+			t.Errorf("suffix(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
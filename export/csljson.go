@@ -0,0 +1,77 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// CSLJSON is the Citation Style Language's JSON data format, used by Pandoc
+// and Zotero.
+var CSLJSON = Format{
+	Name:        "csl-json",
+	ContentType: "application/vnd.citationstyles.csl+json; charset=utf-8",
+	Extension:   ".json",
+	write:       writeCSLJSON,
+}
+
+func init() {
+	register(CSLJSON)
+}
+
+type cslItem struct {
+	ID          string    `json:"id"`
+	CitationKey string    `json:"citation-key"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title,omitempty"`
+	Author      []cslName `json:"author,omitempty"`
+	Publisher   string    `json:"publisher,omitempty"`
+	Issued      *cslDate  `json:"issued,omitempty"`
+	ISBN        string    `json:"ISBN,omitempty"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func writeCSLJSON(w io.Writer, books []models.Book, opts Options) error {
+	keys := citationKeys(books, opts.Library)
+
+	items := make([]cslItem, len(books))
+	for i, book := range books {
+		item := cslItem{
+			ID:          keys[i],
+			CitationKey: keys[i],
+			Type:        "book",
+			Title:       book.Name,
+			Publisher:   book.Publisher,
+			ISBN:        book.ISBN,
+		}
+		for _, n := range authorNames(book.Author) {
+			if n.Given == "" {
+				item.Author = append(item.Author, cslName{Literal: n.Family})
+			} else {
+				item.Author = append(item.Author, cslName{Family: n.Family, Given: n.Given})
+			}
+		}
+		if p := book.Published; !p.IsZero() {
+			parts := []int{p.Year()}
+			if !yearOnly(p) {
+				parts = append(parts, int(p.Month()), p.Day())
+			}
+			item.Issued = &cslDate{DateParts: [][]int{parts}}
+		}
+		items[i] = item
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// CSV is a flat spreadsheet with one row per book and a header row.
+var CSV = Format{
+	Name:        "csv",
+	ContentType: "text/csv; charset=utf-8",
+	Extension:   ".csv",
+	write:       writeCSV,
+}
+
+func init() {
+	register(CSV)
+}
+
+func writeCSV(w io.Writer, books []models.Book, opts Options) error {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	// Validate the columns before writing anything.
+	for _, column := range columns {
+		if _, err := Field(models.Book{}, column); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for _, book := range books {
+		for i, column := range columns {
+			row[i], _ = Field(book, column)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
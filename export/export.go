@@ -0,0 +1,144 @@
+// Package export writes the library out in formats other applications can
+// read.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// Options tune a single export.
+type Options struct {
+	// Columns selects and orders the columns of tabular formats. Empty means
+	// DefaultColumns.
+	Columns []string
+	// BaseURL is the absolute URL of the shelf, used by formats that link
+	// back to book pages. Empty means no links.
+	BaseURL string
+	// Goals are the reading goals for formats that show them, such as the
+	// calendar.
+	Goals []models.Goal
+	// Library is the whole shelf when exporting a selection of it. Citation
+	// formats number clashing keys across it so keys do not depend on the
+	// selection.
+	Library []models.Book
+}
+
+// Format is a supported export file format.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	write       func(io.Writer, []models.Book, Options) error
+}
+
+var formats = map[string]Format{}
+
+func register(f Format) {
+	formats[f.Name] = f
+}
+
+// Lookup returns the registered format with the given name.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[strings.ToLower(name)]
+	return f, ok
+}
+
+// Write exports books to w.
+func (f Format) Write(w io.Writer, books []models.Book, opts Options) error {
+	return f.write(w, books, opts)
+}
+
+// DefaultColumns lists every exportable column in its default order.
+var DefaultColumns = []string{
+	"id", "isbn", "name", "author", "type", "status", "rating", "genre",
+	"tags", "pages", "duration", "narrator", "publisher", "published",
+	"added", "started", "finished", "series", "series_order", "link",
+	"cover", "description", "notes",
+}
+
+// Field returns the text of a single column of book. Multi-value fields are
+// joined with "; " and dates are formatted as YYYY-MM-DD.
+func Field(book models.Book, column string) (string, error) {
+	switch column {
+	case "id":
+		return book.ID, nil
+	case "isbn":
+		return book.ISBN, nil
+	case "name":
+		return book.Name, nil
+	case "author":
+		return book.Author, nil
+	case "type":
+		types := make([]string, len(book.Type))
+		for i, t := range book.Type {
+			types[i] = string(t)
+		}
+		return strings.Join(types, "; "), nil
+	case "status":
+		return string(book.Status), nil
+	case "rating":
+		return rating(book.Rating), nil
+	case "genre":
+		return book.Genre, nil
+	case "tags":
+		return strings.Join(book.Tags, "; "), nil
+	case "pages":
+		return number(book.Pages), nil
+	case "duration":
+		return book.Duration.String(), nil
+	case "narrator":
+		return book.Narrator, nil
+	case "publisher":
+		return book.Publisher, nil
+	case "published":
+		return date(book.Published), nil
+	case "added":
+		return date(book.Added), nil
+	case "started":
+		return date(book.Started), nil
+	case "finished":
+		return date(book.Finished), nil
+	case "series":
+		return book.Series, nil
+	case "series_order":
+		return number(book.SeriesOrder), nil
+	case "link":
+		return book.Link, nil
+	case "cover":
+		return book.Cover, nil
+	case "description":
+		return book.Description, nil
+	case "notes":
+		return book.Notes, nil
+	}
+	return "", fmt.Errorf("unknown column %q", column)
+}
+
+// date formats t as YYYY-MM-DD, leaving unset dates empty.
+func date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// rating formats a star rating, leaving unrated books empty.
+func rating(r float64) string {
+	if r == 0 {
+		return ""
+	}
+	return models.FormatRating(r)
+}
+
+// number formats n, leaving zero values empty.
+func number(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", n)
+}
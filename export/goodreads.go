@@ -0,0 +1,91 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// Goodreads is a CSV in the column layout Goodreads and StoryGraph accept
+// for library imports.
+var Goodreads = Format{
+	Name:        "goodreads",
+	ContentType: "text/csv; charset=utf-8",
+	Extension:   ".csv",
+	write:       writeGoodreads,
+}
+
+func init() {
+	register(Goodreads)
+}
+
+var goodreadsHeader = []string{
+	"Title", "Author", "ISBN13", "My Rating", "Exclusive Shelf",
+	"Date Read", "Date Added", "Bookshelves", "My Review",
+}
+
+func writeGoodreads(w io.Writer, books []models.Book, opts Options) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(goodreadsHeader); err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		shelves := append([]string(nil), book.Tags...)
+		if book.Status == models.Abandoned {
+			shelves = append(shelves, "did-not-finish")
+		}
+
+		row := []string{
+			book.Name,
+			book.Author,
+			isbn13(book.ISBN),
+			// Goodreads ratings are whole stars.
+			number(int(math.Round(book.Rating))),
+			goodreadsShelf(book.Status),
+			goodreadsDate(book.Finished),
+			goodreadsDate(book.Added),
+			strings.Join(shelves, ", "),
+			book.Notes,
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// goodreadsShelf maps a status onto one of Goodreads' three exclusive
+// shelves. Abandoned books go back on to-read with a did-not-finish shelf.
+func goodreadsShelf(status models.Status) string {
+	switch status {
+	case models.Completed:
+		return "read"
+	case models.Reading:
+		return "currently-reading"
+	}
+	return "to-read"
+}
+
+func goodreadsDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006/01/02")
+}
+
+// isbn13 returns the ISBN-13 form Goodreads matches on, or the stored value
+// when it is not a valid ISBN.
+func isbn13(code string) string {
+	if canonical, err := isbn.To13(code); err == nil {
+		return canonical
+	}
+	return code
+}
@@ -0,0 +1,213 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// ICal is an iCalendar (RFC 5545) feed of the reading timeline. A book with
+// both Started and Finished becomes an all-day event spanning the two dates;
+// a book with only one of them becomes a single all-day event on that date.
+// Loans become an event spanning the loan plus one on the due date while
+// the book is out, and the deadlines of Options.Goals become events too.
+var ICal = Format{
+	Name:        "ical",
+	ContentType: "text/calendar; charset=utf-8",
+	Extension:   ".ics",
+	write:       writeICal,
+}
+
+func init() {
+	register(ICal)
+}
+
+// event is an all-day calendar event running from Start to End inclusive.
+type event struct {
+	UID         string
+	Start, End  time.Time
+	Summary     string
+	Description string
+	Category    string
+	URL         string
+}
+
+func writeICal(w io.Writer, books []models.Book, opts Options) error {
+	cw := &calendarWriter{w: w, stamp: time.Now().UTC().Format("20060102T150405Z")}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//book-list//Virtual Bookshelf//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("X-WR-CALNAME:" + escapeText("Reading timeline"))
+
+	for _, book := range books {
+		var link string
+		if opts.BaseURL != "" {
+			link = opts.BaseURL + "/book/" + book.ID
+		}
+
+		if start, end, summary := readingSpan(book); !start.IsZero() {
+			cw.event(event{
+				UID:         book.ID + "@book-list",
+				Start:       start,
+				End:         end,
+				Summary:     summary,
+				Description: calendarDescription(book),
+				Category:    book.Genre,
+				URL:         link,
+			})
+		}
+
+		for _, e := range loanEvents(book) {
+			e.URL = link
+			cw.event(e)
+		}
+	}
+
+	for _, goal := range opts.Goals {
+		if goal.Deadline.IsZero() {
+			continue
+		}
+		description := fmt.Sprintf("Finish %d books", goal.Books)
+		if !goal.Start.IsZero() {
+			description += " starting " + goal.Start.Format("2006-01-02")
+		}
+		cw.event(event{
+			UID:         "goal-" + goal.ID + "@book-list",
+			Start:       goal.Deadline,
+			End:         goal.Deadline,
+			Summary:     "Reading goal: " + goal.Name,
+			Description: description,
+		})
+	}
+
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// loanEvents returns the events for a book's loan: the span it is out, from
+// the day it was lent until it came back or is due, and, while it is still
+// out, its due date.
+func loanEvents(book models.Book) []event {
+	loan := book.Loan
+	if loan == nil || loan.Lent.IsZero() {
+		return nil
+	}
+
+	title := book.Name
+	if loan.To != "" {
+		title += " (" + loan.To + ")"
+	}
+
+	end := loan.Lent
+	switch {
+	case !loan.Returned.IsZero():
+		end = loan.Returned
+	case !loan.Due.IsZero():
+		end = loan.Due
+	}
+	if end.Before(loan.Lent) {
+		end = loan.Lent
+	}
+	events := []event{{UID: book.ID + "-loan@book-list", Start: loan.Lent, End: end, Summary: "Lent " + title}}
+
+	if loan.Returned.IsZero() && !loan.Due.IsZero() {
+		events = append(events, event{UID: book.ID + "-due@book-list", Start: loan.Due, End: loan.Due, Summary: "Due back: " + title})
+	}
+	return events
+}
+
+// readingSpan returns the dates an event for book covers and its title. The
+// start is zero when the book has no reading dates.
+func readingSpan(book models.Book) (start, end time.Time, summary string) {
+	title := book.Name
+	if book.Author != "" {
+		title += " by " + book.Author
+	}
+
+	switch {
+	case !book.Started.IsZero() && !book.Finished.IsZero() && !book.Finished.Before(book.Started):
+		return book.Started, book.Finished, "Read " + title
+	case !book.Finished.IsZero():
+		return book.Finished, book.Finished, "Finished " + title
+	case !book.Started.IsZero():
+		return book.Started, book.Started, "Started " + title
+	}
+	return time.Time{}, time.Time{}, ""
+}
+
+func calendarDescription(book models.Book) string {
+	var lines []string
+	if book.Status != "" {
+		lines = append(lines, "Status: "+string(book.Status))
+	}
+	if book.Rating > 0 {
+		lines = append(lines, "Rating: "+models.Stars(book.Rating))
+	}
+	if book.Pages > 0 {
+		lines = append(lines, fmt.Sprintf("Pages: %d", book.Pages))
+	}
+	if book.Duration != 0 {
+		lines = append(lines, "Duration: "+book.Duration.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeText escapes an iCalendar TEXT value.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// calendarWriter writes content lines with CRLF endings, folding them at 75
+// octets without splitting UTF-8 sequences. The first error is kept.
+type calendarWriter struct {
+	w     io.Writer
+	stamp string // DTSTAMP of every event
+	err   error
+}
+
+func (cw *calendarWriter) event(e event) {
+	cw.line("BEGIN:VEVENT")
+	cw.line("UID:" + escapeText(e.UID))
+	cw.line("DTSTAMP:" + cw.stamp)
+	cw.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+	// DTEND is exclusive for all-day events.
+	cw.line("DTEND;VALUE=DATE:" + e.End.AddDate(0, 0, 1).Format("20060102"))
+	cw.line("SUMMARY:" + escapeText(e.Summary))
+	if e.Description != "" {
+		cw.line("DESCRIPTION:" + escapeText(e.Description))
+	}
+	if e.Category != "" {
+		cw.line("CATEGORIES:" + escapeText(e.Category))
+	}
+	if e.URL != "" {
+		cw.line("URL:" + e.URL)
+	}
+	cw.line("TRANSP:TRANSPARENT")
+	cw.line("END:VEVENT")
+}
+
+func (cw *calendarWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	b.WriteString("\r\n")
+
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
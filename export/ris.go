@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// RIS is the tagged format read by EndNote, Zotero and Mendeley.
+var RIS = Format{
+	Name:        "ris",
+	ContentType: "application/x-research-info-systems; charset=utf-8",
+	Extension:   ".ris",
+	write:       writeRIS,
+}
+
+func init() {
+	register(RIS)
+}
+
+func writeRIS(w io.Writer, books []models.Book, opts Options) error {
+	bw := bufio.NewWriter(w)
+	keys := citationKeys(books, opts.Library)
+
+	for i, book := range books {
+		risTag(bw, "TY", "BOOK")
+		risTag(bw, "ID", keys[i])
+		for _, n := range authorNames(book.Author) {
+			risTag(bw, "AU", n.String())
+		}
+		risTag(bw, "TI", book.Name)
+		risTag(bw, "PB", book.Publisher)
+		if !book.Published.IsZero() {
+			risTag(bw, "PY", fmt.Sprint(book.Published.Year()))
+			if !yearOnly(book.Published) {
+				risTag(bw, "DA", book.Published.Format("2006/01/02"))
+			}
+		}
+		risTag(bw, "SN", book.ISBN)
+		bw.WriteString("ER  - \r\n")
+	}
+
+	return bw.Flush()
+}
+
+// risTag writes a tag line, skipping empty values. RIS values are single
+// lines, so line breaks are replaced by spaces.
+func risTag(w *bufio.Writer, tag, value string) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value != "" {
+		fmt.Fprintf(w, "%s  - %s\r\n", tag, value)
+	}
+}
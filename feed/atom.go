@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	web "github.com/rahutchinson/book-list/web"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    atomContent    `xml:"content"`
+	Links      []atomLink     `xml:"link"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// WriteAtom writes f as an Atom 1.0 document.
+func WriteAtom(w io.Writer, f Feed) error {
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	doc := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		ID:      f.Self,
+		Title:   f.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "alternate", Href: f.Link, Type: "text/html"},
+			{Rel: "self", Href: f.Self, Type: "application/atom+xml"},
+		},
+	}
+
+	for _, item := range f.Items {
+		date := item.Published
+		if date.IsZero() {
+			date = updated
+		}
+		entry := atomEntry{
+			ID:      item.ID,
+			Title:   item.Title,
+			Updated: date.UTC().Format(time.RFC3339),
+			Summary: item.Summary,
+			Content: atomContent{Type: "html", Body: item.Content},
+			Links:   []atomLink{{Rel: "alternate", Href: item.Link, Type: "text/html"}},
+		}
+		if !item.Published.IsZero() {
+			entry.Published = entry.Updated
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+		for _, c := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		if item.Image != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: "enclosure", Href: item.Image, Type: web.ImageType(item.Image)})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
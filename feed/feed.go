@@ -0,0 +1,193 @@
+// Package feed writes syndication feeds of books.
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+	web "github.com/rahutchinson/book-list/web"
+)
+
+// Feed is a format-neutral feed.
+type Feed struct {
+	Title       string
+	Link        string // page the feed describes
+	Self        string // URL of the feed itself
+	Description string
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is a single feed entry.
+type Item struct {
+	ID         string
+	Title      string
+	Link       string
+	Author     string
+	Summary    string
+	Content    string // HTML
+	Image      string
+	Categories []string
+	Published  time.Time
+}
+
+// BookItem turns a book into a feed item dated at date. base is the site
+// root used for links; notes are included only when withNotes is set.
+func BookItem(book models.Book, base string, date time.Time, withNotes bool) Item {
+	link := base + "/book/" + url.PathEscape(book.ID)
+	image := web.AbsoluteURL(base, book.Cover)
+
+	var content strings.Builder
+	if image != "" {
+		fmt.Fprintf(&content, `<p><img src="%s" alt="%s" width="160"></p>`, html.EscapeString(image), html.EscapeString(book.Name))
+	}
+	if book.Author != "" {
+		fmt.Fprintf(&content, "<p>by %s</p>", html.EscapeString(book.Author))
+	}
+	if book.Rating > 0 {
+		fmt.Fprintf(&content, "<p>Rating: %s (%s/5)</p>", models.Stars(book.Rating), models.FormatRating(book.Rating))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&content, "<p>%s</p>", html.EscapeString(book.Description))
+	}
+	if withNotes && book.Notes != "" && !book.HideNotes {
+		fmt.Fprintf(&content, "<blockquote>%s</blockquote>", strings.ReplaceAll(html.EscapeString(book.Notes), "\n", "<br>"))
+	}
+
+	item := Item{
+		ID:        "urn:book-list:book:" + book.ID,
+		Title:     book.Name,
+		Link:      link,
+		Author:    book.Author,
+		Summary:   book.Description,
+		Content:   content.String(),
+		Image:     image,
+		Published: date,
+	}
+	if book.Genre != "" {
+		item.Categories = append(item.Categories, book.Genre)
+	}
+	item.Categories = append(item.Categories, book.Tags...)
+	return item
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Media   string     `xml:"xmlns:media,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      *rssLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	Categories  []string      `xml:"category"`
+	Description string        `xml:"description"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Thumbnail   *rssThumbnail `xml:"media:thumbnail"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// WriteRSS writes f as an RSS 2.0 document.
+func WriteRSS(w io.Writer, f Feed) error {
+	doc := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Media:   "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	if f.Self != "" {
+		doc.Channel.AtomLink = &rssLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"}
+	}
+
+	for _, item := range f.Items {
+		ri := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID},
+			Categories:  item.Categories,
+			Description: item.Content,
+		}
+		// RSS <author> must be an email address, so the author goes in the
+		// title instead.
+		if item.Author != "" {
+			ri.Title = item.Title + " by " + item.Author
+		}
+		if !item.Published.IsZero() {
+			ri.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		if item.Image != "" {
+			ri.Thumbnail = &rssThumbnail{URL: item.Image}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, ri)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+// RecentlyFinished returns up to n finished books, most recent first.
+func RecentlyFinished(books []models.Book, n int) []models.Book {
+	return recent(books, n, func(b models.Book) time.Time { return b.Finished })
+}
+
+// RecentlyAdded returns up to n books by the date they were added, most
+// recent first.
+func RecentlyAdded(books []models.Book, n int) []models.Book {
+	return recent(books, n, func(b models.Book) time.Time { return b.Added })
+}
+
+func recent(books []models.Book, n int, date func(models.Book) time.Time) []models.Book {
+	var dated []models.Book
+	for _, book := range books {
+		if !date(book).IsZero() {
+			dated = append(dated, book)
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool { return date(dated[i]).After(date(dated[j])) })
+	if len(dated) > n {
+		dated = dated[:n]
+	}
+	return dated
+}
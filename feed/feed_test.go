@@ -0,0 +1,72 @@
+package feed
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+func testFeed() Feed {
+	base := "https://books.example.com"
+	finished := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	books := []models.Book{
+		{
+			ID: "1", Name: "Dune & <Messiah>", Author: "Frank \"Spice\" Herbert", Rating: 4.5,
+			Description: "Sand, worms & <b>politics</b>", Notes: "Re-read\nslowly", Genre: "Science Fiction",
+			Tags: []string{"classic"}, Cover: "/covers/dune.jpg",
+		},
+		{ID: "2", Name: "Émile", Author: "Rousseau", Notes: "Hidden", HideNotes: true},
+	}
+	f := Feed{
+		Title:       "Shelf: Recently Finished",
+		Link:        base + "/",
+		Self:        base + "/feeds/finished.atom",
+		Description: "Books most recently finished",
+		Updated:     finished,
+	}
+	for _, book := range books {
+		f.Items = append(f.Items, BookItem(book, base, finished, true))
+	}
+	return f
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAtom(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "finished.atom", buf.Bytes())
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteRSS(&buf, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "finished.rss", buf.Bytes())
+}
@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Audible is an Audible library export as written by audible-cli's
+// "library export" in CSV, TSV or JSON. The layout is detected from the
+// content.
+var Audible = Format{
+	Name: "audible",
+	Mapping: Mapping{
+		"name":        "title",
+		"subtitle":    "subtitle",
+		"author":      "authors",
+		"narrator":    "narrators",
+		"series":      "series_title",
+		"series_pos":  "series_sequence",
+		"genre":       "genres",
+		"runtime":     "runtime_length_min",
+		"finished":    "is_finished",
+		"progress":    "percent_complete",
+		"rating":      "rating",
+		"added":       "date_added",
+		"purchased":   "purchase_date",
+		"published":   "release_date",
+		"cover":       "cover_url",
+		"isbn":        "isbn",
+		"asin":        "asin",
+		"description": "extended_product_description",
+	},
+	convert: convertAudible,
+	read:    readAudible,
+}
+
+func init() {
+	register(Audible)
+}
+
+var audibleDates = []string{time.RFC3339, "2006-01-02T15:04:05.999999Z07:00", "2006-01-02 15:04:05", "2006-01-02", "01-02-06", "01/02/2006"}
+
+func convertAudible(r record) models.Book {
+	book := models.Book{
+		ISBN:        isbn.Normalize(r.get("isbn")),
+		Name:        r.get("name"),
+		Author:      r.get("author"),
+		Narrator:    r.get("narrator"),
+		Type:        []models.BookType{models.Audible},
+		Series:      r.get("series"),
+		SeriesOrder: int(parseFloat(r.get("series_pos"))),
+		Duration:    audibleRuntime(r.get("runtime")),
+		Rating:      parseRating(r.get("rating")),
+		Published:   parse.Date(r.get("published"), audibleDates...),
+		Cover:       r.get("cover"),
+		Description: r.get("description"),
+	}
+
+	// Prefer the purchase date for when the book joined the library.
+	book.Added = parse.Date(r.get("purchased"), audibleDates...)
+	if book.Added.IsZero() {
+		book.Added = parse.Date(r.get("added"), audibleDates...)
+	}
+
+	if genres := parse.List(r.get("genre"), ","); len(genres) > 0 {
+		book.Genre = genres[0]
+	}
+
+	if asin := r.get("asin"); asin != "" {
+		book.Link = "https://www.audible.com/pd/" + asin
+	}
+
+	finished, _ := strconv.ParseBool(r.get("finished"))
+	progress := parseFloat(strings.TrimSuffix(r.get("progress"), "%"))
+	switch {
+	case finished || progress >= 100:
+		book.Status = models.Completed
+	case progress > 0:
+		book.Status = models.Reading
+	default:
+		book.Status = models.Unread
+	}
+
+	return book
+}
+
+// audibleRuntime reads a runtime given either as a number of minutes or as
+// text such as "12 hrs and 30 mins".
+func audibleRuntime(value string) models.Duration {
+	if minutes, err := strconv.ParseFloat(value, 64); err == nil {
+		return models.Duration(time.Duration(minutes * float64(time.Minute)))
+	}
+	d, _ := models.ParseDuration(value)
+	return d
+}
+
+func parseFloat(value string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return f
+}
+
+// readAudible sniffs the export and reads it as JSON, TSV or CSV.
+func readAudible(r io.Reader, mapping Mapping) ([]record, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4096)
+	trimmed := bytes.TrimLeft(head, " \t\r\n\ufeff")
+
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return readJSONRecords(br, mapping)
+	}
+
+	firstLine := head
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		firstLine = head[:i]
+	}
+	if bytes.Count(firstLine, []byte("\t")) > bytes.Count(firstLine, []byte(",")) {
+		return readRecords(br, '\t', mapping)
+	}
+	return readRecords(br, ',', mapping)
+}
+
+// readJSONRecords reads a JSON array of flat objects, or an object holding
+// such an array under "items". Nested values are flattened to text.
+func readJSONRecords(r io.Reader, mapping Mapping) ([]record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("\ufeff"))
+
+	var items []map[string]interface{}
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, err
+		}
+		items = wrapper.Items
+	} else if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	records := make([]record, 0, len(items))
+	for _, item := range items {
+		row := make(map[string]string, len(item))
+		for key, value := range item {
+			row[key] = jsonText(value)
+		}
+		records = append(records, record{row: row, mapping: mapping})
+	}
+	return records, nil
+}
+
+// jsonText flattens a decoded JSON value. Lists of people such as
+// [{"name": "..."}] become a comma-separated list of names.
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok {
+			return name
+		}
+		if title, ok := v["title"].(string); ok {
+			return title
+		}
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if text := jsonText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+
+	models "github.com/rahutchinson/book-list/models"
+	opf "github.com/rahutchinson/book-list/opf"
+)
+
+// Calibre walks a Calibre library directory and reads the metadata.opf file
+// Calibre keeps next to each book. Cover is set to the path of the book's
+// local cover image, for the caller to store.
+func Calibre(dir string) ([]models.Book, error) {
+	var books []models.Book
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "metadata.opf" {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		pkg, err := opf.Parse(f)
+		f.Close()
+		if err != nil {
+			return nil
+		}
+
+		book := pkg.Book()
+		book.Type = []models.BookType{models.Ebook}
+		bookDir := filepath.Dir(path)
+		if href := pkg.CoverHref(); href != "" {
+			book.Cover = localFile(filepath.Join(bookDir, filepath.FromSlash(href)))
+		}
+		if book.Cover == "" {
+			book.Cover = localFile(filepath.Join(bookDir, "cover.jpg"))
+		}
+
+		if book.Name != "" {
+			books = append(books, book)
+		}
+		return nil
+	})
+	return books, err
+}
+
+// localFile returns path if it names an existing regular file.
+func localFile(path string) string {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	return path
+}
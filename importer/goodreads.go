@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"strings"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Goodreads is the CSV produced by Goodreads' "Export Library" tool.
+var Goodreads = Format{
+	Name:  "goodreads",
+	Comma: ',',
+	Mapping: Mapping{
+		"name":      "Title",
+		"author":    "Author",
+		"isbn":      "ISBN",
+		"isbn13":    "ISBN13",
+		"rating":    "My Rating",
+		"publisher": "Publisher",
+		"binding":   "Binding",
+		"pages":     "Number of Pages",
+		"published": "Year Published",
+		"finished":  "Date Read",
+		"added":     "Date Added",
+		"shelves":   "Bookshelves",
+		"status":    "Exclusive Shelf",
+		"notes":     "My Review",
+	},
+	convert: convertGoodreads,
+}
+
+func init() {
+	register(Goodreads)
+}
+
+func convertGoodreads(r record) models.Book {
+	code := isbn.Normalize(r.get("isbn13"))
+	if code == "" {
+		code = isbn.Normalize(r.get("isbn"))
+	}
+
+	shelf := r.get("status")
+	book := models.Book{
+		ISBN:      code,
+		Name:      r.get("name"),
+		Author:    r.get("author"),
+		Rating:    parseRating(r.get("rating")),
+		Publisher: r.get("publisher"),
+		Pages:     parseInt(r.get("pages")),
+		Published: parse.Date(r.get("published"), "2006"),
+		Finished:  parse.Date(r.get("finished"), "2006/01/02", "2006-01-02"),
+		Added:     parse.Date(r.get("added"), "2006/01/02", "2006-01-02"),
+		Status:    shelfStatus(shelf),
+		Notes:     goodreadsNotes(r.get("notes")),
+	}
+
+	if t := bindingType(r.get("binding")); t != "" {
+		book.Type = []models.BookType{t}
+	}
+
+	// Every shelf other than the exclusive one becomes a tag.
+	for _, s := range parse.List(r.get("shelves"), ",") {
+		if s != shelf && !containsFold(book.Tags, s) {
+			book.Tags = append(book.Tags, s)
+		}
+	}
+
+	return book
+}
+
+// goodreadsNotes turns the HTML-ish review text into plain notes. "Private
+// Notes" are left out on purpose: notes show up on shared shelves and in
+// feeds, so only the review, which Goodreads already shows to others, is
+// imported.
+func goodreadsNotes(review string) string {
+	replacer := strings.NewReplacer("<br/>", "\n", "<br />", "\n", "<br>", "\n")
+	return strings.TrimSpace(replacer.Replace(review))
+}
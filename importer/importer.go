@@ -0,0 +1,436 @@
+// Package importer converts reading-history exports from other services into
+// models.Book values and merges them into an existing library.
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Mapping maps a book field name (e.g. "name", "isbn", "finished") to the
+// column header that holds it in the source file.
+type Mapping map[string]string
+
+// Merge returns a copy of m with the entries of override applied on top.
+func (m Mapping) Merge(override Mapping) Mapping {
+	merged := make(Mapping, len(m)+len(override))
+	for field, column := range m {
+		merged[field] = column
+	}
+	for field, column := range override {
+		merged[field] = column
+	}
+	return merged
+}
+
+// Format is a supported export layout. Each format knows its default column
+// mapping and how to turn a row into a book.
+type Format struct {
+	Name    string
+	Comma   rune
+	Mapping Mapping
+	convert func(record) models.Book
+	// read replaces the delimited reader for formats that are not simple
+	// CSV or TSV files.
+	read func(io.Reader, Mapping) ([]record, error)
+}
+
+var formats = map[string]Format{}
+
+func register(f Format) {
+	formats[f.Name] = f
+}
+
+// Lookup returns the registered format with the given name.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[strings.ToLower(name)]
+	return f, ok
+}
+
+// Parse reads an export in this format. Entries in override replace the
+// format's default column mapping.
+func (f Format) Parse(r io.Reader, override Mapping) ([]models.Book, error) {
+	read := f.read
+	if read == nil {
+		read = func(r io.Reader, m Mapping) ([]record, error) {
+			return readRecords(r, f.Comma, m)
+		}
+	}
+
+	records, err := read(r, f.Mapping.Merge(override))
+	if err != nil {
+		return nil, err
+	}
+
+	var books []models.Book
+	for _, rec := range records {
+		book := f.convert(rec)
+		if book.Name == "" {
+			continue
+		}
+		// Exports link covers by URL. Anything else is not a cover the
+		// shelf can show.
+		if !strings.HasPrefix(book.Cover, "http://") && !strings.HasPrefix(book.Cover, "https://") {
+			book.Cover = ""
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+// Action describes what an import does to a single book.
+type Action string
+
+const (
+	Add    Action = "add"
+	Update Action = "update"
+	Skip   Action = "skip"
+)
+
+// Change is one line of an import diff.
+type Change struct {
+	Action     Action      `json:"action"`
+	Book       models.Book `json:"book"`
+	ExistingID string      `json:"existing_id,omitempty"`
+	Fields     []string    `json:"fields,omitempty"`
+}
+
+// Result is the outcome of merging imported books into a library. Books holds
+// the merged library and is only meant to be saved when not doing a dry run.
+type Result struct {
+	Added   int           `json:"added"`
+	Updated int           `json:"updated"`
+	Skipped int           `json:"skipped"`
+	Changes []Change      `json:"changes"`
+	Books   []models.Book `json:"-"`
+}
+
+// Merge folds incoming books into existing ones. Books are matched by ISBN,
+// or by title and author when either side has no ISBN, and updated in place
+// rather than duplicated; unmatched books are appended with an ID from newID.
+// Two editions with different ISBNs are kept apart even when their titles
+// and authors agree.
+func Merge(existing, incoming []models.Book, newID func() string) Result {
+	result := Result{Books: append([]models.Book(nil), existing...)}
+
+	byISBN := make(map[string]int)
+	byTitle := make(map[string][]int)
+	index := func(i int) {
+		if key := isbn.Normalize(result.Books[i].ISBN); key != "" {
+			byISBN[key] = i
+		}
+		if key := titleKey(result.Books[i]); key != "" {
+			for _, j := range byTitle[key] {
+				if j == i {
+					return
+				}
+			}
+			byTitle[key] = append(byTitle[key], i)
+		}
+	}
+	for i := range result.Books {
+		index(i)
+	}
+
+	for _, book := range incoming {
+		book.ISBN = isbn.Normalize(book.ISBN)
+		i, found := -1, false
+		if book.ISBN != "" {
+			i, found = byISBN[book.ISBN]
+		}
+		if !found {
+			for _, j := range byTitle[titleKey(book)] {
+				if book.ISBN == "" || result.Books[j].ISBN == "" {
+					i, found = j, true
+					break
+				}
+			}
+		}
+
+		if !found {
+			book.ID = newID()
+			if book.Added.IsZero() {
+				book.Added = time.Now()
+			}
+			if book.Status == "" {
+				book.Status = models.Unread
+			}
+			result.Books = append(result.Books, book)
+			index(len(result.Books) - 1)
+			result.Added++
+			result.Changes = append(result.Changes, Change{Action: Add, Book: book})
+			continue
+		}
+
+		fields := mergeBook(&result.Books[i], book)
+		index(i)
+		if len(fields) == 0 {
+			result.Skipped++
+			result.Changes = append(result.Changes, Change{Action: Skip, Book: result.Books[i], ExistingID: result.Books[i].ID})
+			continue
+		}
+		result.Updated++
+		result.Changes = append(result.Changes, Change{Action: Update, Book: result.Books[i], ExistingID: result.Books[i].ID, Fields: fields})
+	}
+
+	return result
+}
+
+// titleKey identifies a book by normalized title and author.
+func titleKey(book models.Book) string {
+	name := strings.ToLower(strings.Join(strings.Fields(book.Name), " "))
+	author := strings.ToLower(strings.Join(strings.Fields(book.Author), " "))
+	if name == "" || author == "" {
+		return ""
+	}
+	return name + "|" + author
+}
+
+// mergeBook copies every non-empty field of src that differs from dst into
+// dst and returns the names of the fields it changed. Tags are unioned. The
+// ISBN, title and notes are only filled in when dst has none, so an import
+// never replaces what the user entered.
+func mergeBook(dst *models.Book, src models.Book) []string {
+	var fields []string
+	setString := func(name string, d *string, s string) {
+		if s != "" && *d != s {
+			*d = s
+			fields = append(fields, name)
+		}
+	}
+	fillString := func(name string, d *string, s string) {
+		if *d == "" {
+			setString(name, d, s)
+		}
+	}
+	setInt := func(name string, d *int, s int) {
+		if s != 0 && *d != s {
+			*d = s
+			fields = append(fields, name)
+		}
+	}
+	setFloat := func(name string, d *float64, s float64) {
+		if s != 0 && *d != s {
+			*d = s
+			fields = append(fields, name)
+		}
+	}
+	setTime := func(name string, d *time.Time, s time.Time) {
+		if !s.IsZero() && !d.Equal(s) {
+			*d = s
+			fields = append(fields, name)
+		}
+	}
+
+	fillString("isbn", &dst.ISBN, src.ISBN)
+	fillString("name", &dst.Name, src.Name)
+	setString("author", &dst.Author, src.Author)
+	setString("description", &dst.Description, src.Description)
+	setString("cover", &dst.Cover, src.Cover)
+	setString("genre", &dst.Genre, src.Genre)
+	setString("link", &dst.Link, src.Link)
+	setString("publisher", &dst.Publisher, src.Publisher)
+	fillString("notes", &dst.Notes, src.Notes)
+	setString("series", &dst.Series, src.Series)
+	setString("narrator", &dst.Narrator, src.Narrator)
+	setFloat("rating", &dst.Rating, src.Rating)
+	setInt("pages", &dst.Pages, src.Pages)
+	setInt("series_order", &dst.SeriesOrder, src.SeriesOrder)
+	setTime("published", &dst.Published, src.Published)
+	setTime("started", &dst.Started, src.Started)
+	setTime("finished", &dst.Finished, src.Finished)
+
+	if src.Duration != 0 && dst.Duration != src.Duration {
+		dst.Duration = src.Duration
+		fields = append(fields, "duration")
+	}
+
+	if src.Status != "" && dst.Status != src.Status {
+		dst.Status = src.Status
+		fields = append(fields, "status")
+	}
+
+	for _, t := range src.Type {
+		if !containsType(dst.Type, t) {
+			dst.Type = append(dst.Type, t)
+			fields = appendOnce(fields, "type")
+		}
+	}
+
+	for _, tag := range src.Tags {
+		if !containsFold(dst.Tags, tag) {
+			dst.Tags = append(dst.Tags, tag)
+			fields = appendOnce(fields, "tags")
+		}
+	}
+
+	return fields
+}
+
+// record is one row of a delimited export, addressed by book field name.
+type record struct {
+	row     map[string]string
+	mapping Mapping
+}
+
+func (r record) get(field string) string {
+	column, ok := r.mapping[field]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(r.row[column])
+}
+
+// readRecords reads a delimited file with a header row.
+func readRecords(rd io.Reader, comma rune, mapping Mapping) ([]record, error) {
+	reader := csv.NewReader(rd)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var records []record
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(fields) {
+				row[column] = fields[i]
+			}
+		}
+		records = append(records, record{row: row, mapping: mapping})
+	}
+
+	return records, nil
+}
+
+// parseRating parses a star rating, keeping fractional stars to two decimal
+// places and clamping to 0-5.
+func parseRating(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return math.Max(0, math.Min(5, math.Round(f*100)/100))
+}
+
+func parseInt(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// shelfStatus maps a reading-status shelf name onto a Status. Services spell
+// these with hyphens, underscores or spaces, so all three are accepted.
+func shelfStatus(shelf string) models.Status {
+	s := strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(strings.TrimSpace(shelf)))
+	switch s {
+	case "":
+		return ""
+	case "read", "finished", "completed":
+		return models.Completed
+	case "currently reading", "reading":
+		return models.Reading
+	case "to read", "want to read", "wishlist":
+		return models.WantToRead
+	case "did not finish", "dnf", "abandoned":
+		return models.Abandoned
+	}
+	return models.Unread
+}
+
+// bindingType maps a free-form binding or format description onto a BookType.
+func bindingType(binding string) models.BookType {
+	b := strings.ToLower(binding)
+	switch {
+	case b == "":
+		return ""
+	case strings.Contains(b, "kindle"):
+		return models.Kindle
+	case strings.Contains(b, "audio"):
+		return models.Audible
+	case strings.Contains(b, "ebook"), strings.Contains(b, "e-book"),
+		strings.Contains(b, "digital"), strings.Contains(b, "epub"):
+		return models.Ebook
+	}
+	return models.Physical
+}
+
+// looksLikeISBN reports whether a cleaned value has the shape of an ISBN-10
+// or ISBN-13.
+func looksLikeISBN(value string) bool {
+	if len(value) != 10 && len(value) != 13 {
+		return false
+	}
+	for i, c := range value {
+		if c < '0' || c > '9' {
+			if !(c == 'X' && i == 9 && len(value) == 10) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// firstAuthor returns the first name of a comma-separated author list.
+func firstAuthor(authors string) string {
+	if list := parse.List(authors, ","); len(list) > 0 {
+		return list[0]
+	}
+	return ""
+}
+
+func containsType(types []models.BookType, t models.BookType) bool {
+	for _, existing := range types {
+		if existing == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendOnce(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,196 @@
+package importer
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		override Mapping
+		want     []models.Book
+	}{
+		{
+			name:   "goodreads defaults",
+			format: "goodreads",
+			input: "Title,Author,ISBN,ISBN13,My Rating,Number of Pages,Year Published,Date Read,Exclusive Shelf,Binding,My Review,Private Notes\n" +
+				`The Hobbit,J.R.R. Tolkien,"=""054792822X""","=""""",4,300,1937,2024/03/01,read,Paperback,Loved it,secret` + "\n",
+			want: []models.Book{{
+				ISBN: "9780547928227", Name: "The Hobbit", Author: "J.R.R. Tolkien", Rating: 4, Pages: 300,
+				Published: date(1937, 1, 1), Finished: date(2024, 3, 1), Status: models.Completed,
+				Type: []models.BookType{models.Physical}, Notes: "Loved it",
+			}},
+		},
+		{
+			name:     "override replaces one column",
+			format:   "goodreads",
+			input:    "Title,Author,Date Read,Date Finished\nDune,Frank Herbert,2020/01/01,2021-06-30\n",
+			override: Mapping{"finished": "Date Finished"},
+			want:     []models.Book{{Name: "Dune", Author: "Frank Herbert", Finished: date(2021, 6, 30)}},
+		},
+		{
+			name:     "override maps a missing column to nothing",
+			format:   "goodreads",
+			input:    "Title,Author,My Review\nDune,Frank Herbert,Spice\n",
+			override: Mapping{"notes": "Not There"},
+			want:     []models.Book{{Name: "Dune", Author: "Frank Herbert"}},
+		},
+		{
+			name:   "storygraph ISO read range and fractional rating",
+			format: "storygraph",
+			input: "Title,Authors,ISBN/UID,Read Status,Dates Read,Star Rating,Tags,Moods\n" +
+				`Dune,"Frank Herbert, Brian Herbert",9780441013593,read,2021-01-05-2021-02-10,3.75,"classic","adventurous"` + "\n",
+			want: []models.Book{{
+				ISBN: "9780441013593", Name: "Dune", Author: "Frank Herbert", Status: models.Completed,
+				Started: date(2021, 1, 5), Finished: date(2021, 2, 10), Rating: 3.75,
+				Tags: []string{"classic", "adventurous"},
+			}},
+		},
+		{
+			name:   "rows without a title and non-URL covers are dropped",
+			format: "audible",
+			input:  "title,authors,cover_url,is_finished\n,Nobody,,true\nDune,Frank Herbert,shares.json,true\n",
+			want: []models.Book{{
+				Name: "Dune", Author: "Frank Herbert", Status: models.Completed,
+				Type: []models.BookType{models.Audible},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := Lookup(tt.format)
+			if !ok {
+				t.Fatalf("format %q is not registered", tt.format)
+			}
+			got, err := f.Parse(strings.NewReader(tt.input), tt.override)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingMerge(t *testing.T) {
+	base := Mapping{"name": "Title", "notes": "My Review"}
+	merged := base.Merge(Mapping{"notes": "", "finished": "Date Finished"})
+
+	want := Mapping{"name": "Title", "notes": "", "finished": "Date Finished"}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge = %v, want %v", merged, want)
+	}
+	if base["notes"] != "My Review" {
+		t.Error("Merge changed the base mapping")
+	}
+}
+
+func TestMergeMatchesISBNForms(t *testing.T) {
+	existing := []models.Book{{ID: "1", Name: "The Hobbit", ISBN: "9780547928227", Status: models.Reading}}
+	incoming := []models.Book{
+		{Name: "The Hobbit (Anniversary)", ISBN: "0-547-92822-X", Pages: 300},
+		{Name: "Dune", Author: "Frank Herbert", ISBN: "978-0-441-01359-3"},
+	}
+
+	result := Merge(existing, incoming, func() string { return "2" })
+	if result.Added != 1 || result.Updated != 1 {
+		t.Fatalf("Merge added %d and updated %d, want 1 and 1", result.Added, result.Updated)
+	}
+	if result.Books[0].Pages != 300 {
+		t.Errorf("matched book = %+v, want pages filled in", result.Books[0])
+	}
+	if got := result.Books[1].ISBN; got != "9780441013593" {
+		t.Errorf("added book ISBN = %q, want the canonical ISBN-13", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	next := func() func() string {
+		n := 0
+		return func() string { n++; return "new" + strconv.Itoa(n) }
+	}
+
+	tests := []struct {
+		name     string
+		existing []models.Book
+		incoming []models.Book
+		want     []models.Book
+		added    int
+		updated  int
+	}{
+		{
+			name:     "different ISBNs are different editions",
+			existing: []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593"}},
+			incoming: []models.Book{{Name: "Dune", Author: "Frank Herbert", ISBN: "9780340960196", Status: models.Completed}},
+			want: []models.Book{
+				{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593"},
+				{ID: "new1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780340960196", Status: models.Completed},
+			},
+			added: 1,
+		},
+		{
+			name:     "incoming without an ISBN matches by title and author",
+			existing: []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593"}},
+			incoming: []models.Book{{Name: "dune", Author: "Frank Herbert", Pages: 412}},
+			want:     []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412}},
+			updated:  1,
+		},
+		{
+			name:     "existing without an ISBN matches and gets one",
+			existing: []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert"}},
+			incoming: []models.Book{{Name: "Dune", Author: "Frank Herbert", ISBN: "0441013597"}},
+			want:     []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593"}},
+			updated:  1,
+		},
+		{
+			name:     "title and notes the user entered are kept",
+			existing: []models.Book{{ID: "1", Name: "Dune (my copy)", Author: "Frank Herbert", ISBN: "9780441013593", Notes: "Mine"}},
+			incoming: []models.Book{{Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Notes: "Theirs", Rating: 5}},
+			want:     []models.Book{{ID: "1", Name: "Dune (my copy)", Author: "Frank Herbert", ISBN: "9780441013593", Notes: "Mine", Rating: 5}},
+			updated:  1,
+		},
+		{
+			name:     "blank notes are filled",
+			existing: []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593"}},
+			incoming: []models.Book{{Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Notes: "Spice"}},
+			want:     []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Notes: "Spice"}},
+			updated:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := tt.incoming
+			for i := range incoming {
+				incoming[i].Added = date(2024, 1, 1)
+			}
+			for i := range tt.want {
+				if tt.want[i].ID == "new1" {
+					tt.want[i].Added = date(2024, 1, 1)
+				}
+			}
+
+			result := Merge(tt.existing, incoming, next())
+			if result.Added != tt.added || result.Updated != tt.updated {
+				t.Errorf("Merge added %d and updated %d, want %d and %d", result.Added, result.Updated, tt.added, tt.updated)
+			}
+			if !reflect.DeepEqual(result.Books, tt.want) {
+				t.Errorf("Merge =\n%+v\nwant\n%+v", result.Books, tt.want)
+			}
+		})
+	}
+}
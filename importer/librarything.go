@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"strings"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// LibraryThing is the tab-separated export from LibraryThing's "Export"
+// page.
+var LibraryThing = Format{
+	Name:  "librarything",
+	Comma: '\t',
+	Mapping: Mapping{
+		"name":        "Title",
+		"author":      "Primary Author",
+		"isbn":        "ISBN",
+		"isbns":       "ISBNs",
+		"publisher":   "Publication",
+		"published":   "Date",
+		"notes":       "Review",
+		"comment":     "Comment",
+		"rating":      "Rating",
+		"description": "Summary",
+		"media":       "Media",
+		"pages":       "Page Count",
+		"added":       "Entry Date",
+		"started":     "Date Started",
+		"finished":    "Date Read",
+		"tags":        "Tags",
+		"collections": "Collections",
+		"genre":       "Subjects",
+	},
+	convert: convertLibraryThing,
+}
+
+func init() {
+	register(LibraryThing)
+}
+
+var libraryThingDates = []string{"2006-01-02", "2006/01/02", "2006-01", "2006"}
+
+func convertLibraryThing(r record) models.Book {
+	book := models.Book{
+		ISBN:        libraryThingISBN(r.get("isbn"), r.get("isbns")),
+		Name:        r.get("name"),
+		Author:      r.get("author"),
+		Publisher:   r.get("publisher"),
+		Published:   parse.Date(r.get("published"), libraryThingDates...),
+		Rating:      parseRating(r.get("rating")),
+		Description: r.get("description"),
+		Pages:       parseInt(r.get("pages")),
+		Added:       parse.Date(r.get("added"), libraryThingDates...),
+		Started:     parse.Date(r.get("started"), libraryThingDates...),
+		Finished:    parse.Date(r.get("finished"), libraryThingDates...),
+		Tags:        parse.List(r.get("tags"), ","),
+		Notes:       r.get("notes"),
+	}
+
+	if comment := r.get("comment"); comment != "" {
+		if book.Notes != "" {
+			book.Notes += "\n\n"
+		}
+		book.Notes += comment
+	}
+
+	if t := bindingType(r.get("media")); t != "" {
+		book.Type = []models.BookType{t}
+	}
+
+	// Subjects look like "Fantasy fiction -- England"; keep the first heading.
+	if subjects := parse.List(r.get("genre"), "|"); len(subjects) > 0 {
+		book.Genre = strings.TrimSpace(strings.SplitN(subjects[0], "--", 2)[0])
+	}
+
+	book.Status = collectionStatus(parse.List(r.get("collections"), ","), book)
+
+	return book
+}
+
+// libraryThingISBN picks an ISBN from the bracketed "[0141439513]" column,
+// falling back to the first entry of the comma-separated ISBNs column.
+func libraryThingISBN(code, codes string) string {
+	if code = isbn.Normalize(strings.Trim(code, "[]")); code != "" {
+		return code
+	}
+	if list := parse.List(codes, ","); len(list) > 0 {
+		return isbn.Normalize(list[0])
+	}
+	return ""
+}
+
+// collectionStatus derives a status from LibraryThing collections, which
+// stand in for reading shelves, then from the reading dates.
+func collectionStatus(collections []string, book models.Book) models.Status {
+	for _, c := range collections {
+		switch strings.ToLower(c) {
+		case "currently reading":
+			return models.Reading
+		case "to read", "wishlist":
+			return models.WantToRead
+		case "read but unowned":
+			return models.Completed
+		}
+	}
+
+	switch {
+	case !book.Finished.IsZero():
+		return models.Completed
+	case !book.Started.IsZero():
+		return models.Reading
+	}
+	return models.Unread
+}
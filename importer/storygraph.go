@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// StoryGraph is the CSV produced by StoryGraph's "Export StoryGraph Library".
+var StoryGraph = Format{
+	Name:  "storygraph",
+	Comma: ',',
+	Mapping: Mapping{
+		"name":       "Title",
+		"author":     "Authors",
+		"isbn":       "ISBN/UID",
+		"format":     "Format",
+		"status":     "Read Status",
+		"added":      "Date Added",
+		"finished":   "Last Date Read",
+		"dates_read": "Dates Read",
+		"rating":     "Star Rating",
+		"notes":      "Review",
+		"tags":       "Tags",
+		"moods":      "Moods",
+	},
+	convert: convertStoryGraph,
+}
+
+func init() {
+	register(StoryGraph)
+}
+
+var storyGraphDates = []string{"2006/01/02", "2006-01-02", "2006/1/2"}
+
+// storyGraphDate matches one date of a "Dates Read" range. The dates
+// themselves may contain hyphens, so ranges cannot simply be split on "-".
+var storyGraphDate = regexp.MustCompile(`\d{4}[/-]\d{1,2}[/-]\d{1,2}`)
+
+func convertStoryGraph(r record) models.Book {
+	book := models.Book{
+		ISBN:     isbn.Normalize(r.get("isbn")),
+		Name:     r.get("name"),
+		Author:   firstAuthor(r.get("author")),
+		Status:   shelfStatus(r.get("status")),
+		Added:    parse.Date(r.get("added"), storyGraphDates...),
+		Finished: parse.Date(r.get("finished"), storyGraphDates...),
+		Rating:   parseRating(r.get("rating")),
+		Notes:    r.get("notes"),
+		Tags:     parse.List(r.get("tags"), ","),
+	}
+
+	// StoryGraph ISBN/UID holds an internal ID when the edition has no ISBN.
+	if !looksLikeISBN(book.ISBN) {
+		book.ISBN = ""
+	}
+
+	if t := bindingType(r.get("format")); t != "" {
+		book.Type = []models.BookType{t}
+	}
+
+	// "Dates Read" lists every read as start-end ranges, most recent last.
+	if ranges := parse.List(r.get("dates_read"), ","); len(ranges) > 0 {
+		started, finished := readingRange(ranges[len(ranges)-1])
+		book.Started = started
+		if book.Finished.IsZero() {
+			book.Finished = finished
+		}
+	}
+
+	for _, mood := range parse.List(r.get("moods"), ",") {
+		if !containsFold(book.Tags, mood) {
+			book.Tags = append(book.Tags, mood)
+		}
+	}
+
+	return book
+}
+
+// readingRange parses one "Dates Read" range such as "2023/01/05-2023/01/20"
+// or "2023-01-05 - 2023-01-20". A range with only one date is a read that
+// has not finished, or, when the date follows the separator, one whose start
+// is unknown.
+func readingRange(value string) (started, finished time.Time) {
+	matches := storyGraphDate.FindAllStringIndex(value, 2)
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		date := parse.Date(value[matches[0][0]:matches[0][1]], storyGraphDates...)
+		if strings.HasPrefix(strings.TrimSpace(value[:matches[0][0]]), "-") {
+			return time.Time{}, date
+		}
+		return date, time.Time{}
+	}
+	started = parse.Date(value[matches[0][0]:matches[0][1]], storyGraphDates...)
+	finished = parse.Date(value[matches[1][0]:matches[1][1]], storyGraphDates...)
+	return
+}
@@ -0,0 +1,183 @@
+// Package isbn validates International Standard Book Numbers and converts
+// between the 10- and 13-digit forms.
+//
+// The library stores ISBNs in canonical form: 13 digits without hyphens.
+// Every ISBN-10 has an ISBN-13 with the 978 prefix, so the two forms of the
+// same book compare equal after Canonical.
+package isbn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrEmpty    = errors.New("ISBN is empty")
+	ErrLength   = errors.New("ISBN must have 10 or 13 digits")
+	ErrChecksum = errors.New("ISBN check digit does not match")
+	ErrPrefix   = errors.New("ISBN-13 must start with 978 or 979")
+	// ErrNo10 is returned when converting a 979 ISBN-13, which has no
+	// ISBN-10 form.
+	ErrNo10 = errors.New("ISBN-13 with a 979 prefix has no ISBN-10")
+)
+
+// Error describes an invalid ISBN.
+type Error struct {
+	Input string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid ISBN %q: %v", e.Input, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Clean strips hyphens, spaces, an "ISBN" or "ISBN-13:" label and
+// spreadsheet quoting from s, and upper-cases an X check digit. It does not
+// validate.
+func Clean(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "=")
+	s = strings.Trim(s, `"`)
+	upper := strings.ToUpper(s)
+	for _, label := range []string{"ISBN-13", "ISBN-10", "ISBN13", "ISBN10", "ISBN"} {
+		if strings.HasPrefix(upper, label) {
+			upper = strings.TrimLeft(upper[len(label):], ": ")
+			break
+		}
+	}
+	return strings.NewReplacer("-", "", " ", "", "‐", "", "‑", "", "–", "").Replace(upper)
+}
+
+// Validate reports why s is not a valid ISBN-10 or ISBN-13, or nil.
+func Validate(s string) error {
+	_, err := Canonical(s)
+	return err
+}
+
+// Valid reports whether s is a valid ISBN-10 or ISBN-13.
+func Valid(s string) bool {
+	return Validate(s) == nil
+}
+
+// Canonical validates s and returns it as a 13-digit ISBN without hyphens.
+func Canonical(s string) (string, error) {
+	clean := Clean(s)
+	switch len(clean) {
+	case 0:
+		return "", &Error{Input: s, Err: ErrEmpty}
+	case 10:
+		if err := check10(clean); err != nil {
+			return "", &Error{Input: s, Err: err}
+		}
+		return convert10(clean), nil
+	case 13:
+		if err := check13(clean); err != nil {
+			return "", &Error{Input: s, Err: err}
+		}
+		return clean, nil
+	}
+	return "", &Error{Input: s, Err: ErrLength}
+}
+
+// Normalize returns a valid ISBN in canonical form and anything else as
+// Clean leaves it, for matching and storing codes that may not be ISBNs at
+// all, such as a catalogue's internal IDs.
+func Normalize(s string) string {
+	if canonical, err := Canonical(s); err == nil {
+		return canonical
+	}
+	return Clean(s)
+}
+
+// To13 converts a valid ISBN-10 or ISBN-13 to its ISBN-13 form.
+func To13(s string) (string, error) {
+	return Canonical(s)
+}
+
+// To10 converts a valid ISBN-10 or 978 ISBN-13 to its ISBN-10 form.
+func To10(s string) (string, error) {
+	isbn13, err := Canonical(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", &Error{Input: s, Err: ErrNo10}
+	}
+	body := isbn13[3:12]
+	return body + checkDigit10(body), nil
+}
+
+// Equal reports whether a and b are the same valid ISBN, in either form.
+func Equal(a, b string) bool {
+	ca, err := Canonical(a)
+	if err != nil {
+		return false
+	}
+	cb, err := Canonical(b)
+	return err == nil && ca == cb
+}
+
+func check10(s string) error {
+	for i, c := range s {
+		if c >= '0' && c <= '9' || c == 'X' && i == 9 {
+			continue
+		}
+		return fmt.Errorf("unexpected character %q", c)
+	}
+	if checkDigit10(s[:9]) != s[9:] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func check13(s string) error {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return ErrPrefix
+	}
+	if checkDigit13(s[:12]) != s[12:] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// checkDigit10 computes the check digit for the first nine digits of an
+// ISBN-10: weights 10 down to 2, modulo 11, with 10 written as X.
+func checkDigit10(body string) string {
+	sum := 0
+	for i, c := range body {
+		sum += int(c-'0') * (10 - i)
+	}
+	switch d := (11 - sum%11) % 11; d {
+	case 10:
+		return "X"
+	default:
+		return string(rune('0' + d))
+	}
+}
+
+// checkDigit13 computes the check digit for the first twelve digits of an
+// ISBN-13: alternating weights 1 and 3, modulo 10.
+func checkDigit13(body string) string {
+	sum := 0
+	for i, c := range body {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+func convert10(s string) string {
+	body := "978" + s[:9]
+	return body + checkDigit13(body)
+}
@@ -0,0 +1,93 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{input: "9780547928227", want: "9780547928227"},
+		{input: "978-0-547-92822-7", want: "9780547928227"},
+		{input: "054792822X", want: "9780547928227"},
+		{input: "0-547-92822-x", want: "9780547928227"},
+		{input: "ISBN-13: 978-0-547-92822-7", want: "9780547928227"},
+		{input: "ISBN 0 306 40615 2", want: "9780306406157"},
+		{input: `="054792822X"`, want: "9780547928227"},
+		{input: "979-10-90636-07-1", want: "9791090636071"},
+		{input: "", err: ErrEmpty},
+		{input: "  ", err: ErrEmpty},
+		{input: "12345", err: ErrLength},
+		{input: "97805479282270", err: ErrLength},
+		{input: "9780547928228", err: ErrChecksum},
+		{input: "0547928227", err: ErrChecksum},
+		{input: "9770547928227", err: ErrPrefix},
+	}
+
+	for _, tt := range tests {
+		got, err := Canonical(tt.input)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Canonical(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Canonical(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestCanonicalRejectsLetters(t *testing.T) {
+	for _, input := range []string{"05479X822X", "978054792822X", "B00ABCDEFG"} {
+		if _, err := Canonical(input); err == nil {
+			t.Errorf("Canonical(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{input: "9780547928227", want: "054792822X"},
+		{input: "9780306406157", want: "0306406152"},
+		{input: "0306406152", want: "0306406152"},
+		{input: "9791090636071", err: ErrNo10},
+	}
+
+	for _, tt := range tests {
+		got, err := To10(tt.input)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("To10(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("To10(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"0-547-92822-x":       "9780547928227",
+		`="9780547928227"`:    "9780547928227",
+		"ISBN 978-0306406157": "9780306406157",
+		"978-0-306-40615-8":   "9780306406158",
+		"sg-12345":            "SG12345",
+		"":                    "",
+	}
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -2,6 +2,10 @@ $(document).ready(function() {
     let allBooks = [];
     let currentView = 'bookshelf';
     
+    // A static build (go run main.go build) has no API: books and statistics
+    // come from the JSON files written next to the page
+    const staticSite = document.body.dataset.static === 'true';
+    
     // Initialize the application
     init();
     
@@ -13,7 +17,7 @@ $(document).ready(function() {
     
     function setupEventListeners() {
         // Navigation
-        $('.nav-link').on('click', function(e) {
+        $('.nav-link[data-view]').on('click', function(e) {
             e.preventDefault();
             const view = $(this).data('view');
             switchView(view);
@@ -70,10 +74,25 @@ $(document).ready(function() {
         // ISBN lookup for add book form
         $('#lookupISBN').on('click', function() {
             const isbn = $('#bookISBN').val().trim();
+            const title = $('#bookTitle').val().trim();
+            const author = $('#bookAuthor').val().trim();
             if (isbn) {
                 lookupBookByISBN(isbn, 'add');
+            } else if (title || author) {
+                searchBookCandidates(title, author);
             } else {
-                showToast('Please enter an ISBN', 'error');
+                showToast('Please enter an ISBN or a title', 'error');
+            }
+        });
+        
+        // Barcode photo for add book form
+        $('#scanBarcode').on('click', function() {
+            $('#scanBarcodeInput').trigger('click');
+        });
+        
+        $('#scanBarcodeInput').on('change', function() {
+            if (this.files && this.files[0]) {
+                scanBarcode(this.files[0]);
             }
         });
         
@@ -97,13 +116,14 @@ $(document).ready(function() {
                 author: $('#editBookAuthor').val(),
                 type: $('#editBookType').val(),
                 status: $('#editBookStatus').val(),
-                rating: parseInt($('#editBookRating').val()) || 0,
+                rating: parseFloat($('#editBookRating').val()) || 0,
                 genre: $('#editBookGenre').val(),
                 pages: parseInt($('#editBookPages').val()) || 0,
                 cover: $('#editBookCover').val(),
                 link: $('#editBookLink').val(),
                 description: $('#editBookDescription').val(),
-                notes: $('#editBookNotes').val()
+                notes: $('#editBookNotes').val(),
+                loan: readLoan()
             };
             
             // Check if data has changed
@@ -118,13 +138,14 @@ $(document).ready(function() {
             
             // Clear the original data and reset form
             $('#editBookModal').removeData('original-book');
+            $('#editBookModal').removeData('loan');
             $('#editBookForm')[0].reset();
             $('#editBookCoverPreview').hide();
         });
     }
     
     function setupNavigation() {
-        $('.nav-link').removeClass('active');
+        $('.nav-link[data-view]').removeClass('active');
         $(`.nav-link[data-view="${currentView}"]`).addClass('active');
     }
     
@@ -143,7 +164,7 @@ $(document).ready(function() {
         showLoading('#bookList');
         showLoading('#currentlyReading');
         
-        $.getJSON('/books', function(data) {
+        $.getJSON(staticSite ? 'books.json' : '/books', function(data) {
             allBooks = data.books || [];
             renderBooks();
         }).fail(function() {
@@ -192,11 +213,15 @@ $(document).ready(function() {
         
         // Set book cover
         const coverImg = clone.querySelector('.book-cover');
-        coverImg.src = book.cover || 'https://via.placeholder.com/160x240/f8f9fa/6c757d?text=No+Cover';
+        coverImg.src = bookCover(book, 'medium');
         coverImg.alt = book.name;
         
         // Set book info
         clone.querySelector('.book-title').textContent = book.name;
+        if (staticSite) {
+            // The static build has a page for every book
+            $(clone.querySelector('.book-title')).wrapInner($('<a>').attr('href', 'book/' + encodeURIComponent(book.id) + '/'));
+        }
         clone.querySelector('.book-author').textContent = book.author;
         clone.querySelector('.book-genre').textContent = book.genre || 'No genre';
         
@@ -296,9 +321,12 @@ $(document).ready(function() {
         if (!rating || rating === 0) return '<span class="text-muted">No rating</span>';
         
         let stars = '';
+        const halves = Math.round(rating * 2);
         for (let i = 1; i <= 5; i++) {
-            if (i <= rating) {
+            if (i * 2 <= halves) {
                 stars += '<i class="fas fa-star star"></i>';
+            } else if (i * 2 - 1 === halves) {
+                stars += '<i class="fas fa-star-half-alt star"></i>';
             } else {
                 stars += '<i class="far fa-star star"></i>';
             }
@@ -306,6 +334,16 @@ $(document).ready(function() {
         return stars;
     }
     
+    // Select a rating, adding an option for imported fractional ratings such
+    // as 3.75 so editing the book does not lose them
+    function setRatingOption(select, rating) {
+        const value = String(rating);
+        if ($(select).find('option').filter(function() { return this.value === value; }).length === 0) {
+            $(select).append($('<option>').val(value).text(value + ' Stars'));
+        }
+        $(select).val(value);
+    }
+    
     function addBook() {
         const bookData = {
             name: $('#bookTitle').val(),
@@ -313,7 +351,7 @@ $(document).ready(function() {
             isbn: $('#bookISBN').val(),
             type: $('#bookType').val() || [],
             status: $('#bookStatus').val(),
-            rating: parseInt($('#bookRating').val()) || 0,
+            rating: parseFloat($('#bookRating').val()) || 0,
             genre: $('#bookGenre').val(),
             pages: parseInt($('#bookPages').val()) || 0,
             cover: $('#bookCover').val(),
@@ -334,10 +372,11 @@ $(document).ready(function() {
             success: function() {
                 showToast('Book added successfully!', 'success');
                 $('#addBookForm')[0].reset();
+                $('#lookupCandidates').empty().hide();
                 loadBooks();
             },
-            error: function() {
-                showToast('Failed to add book', 'error');
+            error: function(xhr) {
+                showToast(xhr.status === 400 && xhr.responseText ? xhr.responseText : 'Failed to add book', 'error');
             }
         });
     }
@@ -354,13 +393,18 @@ $(document).ready(function() {
             $('#editBookType').val([book.type]);
         }
         $('#editBookStatus').val(book.status);
-        $('#editBookRating').val(book.rating || 0);
+        setRatingOption('#editBookRating', book.rating || 0);
         $('#editBookGenre').val(book.genre || '');
         $('#editBookPages').val(book.pages || '');
         $('#editBookCover').val(book.cover || '');
         $('#editBookLink').val(book.link || '');
         $('#editBookDescription').val(book.description || '');
         $('#editBookNotes').val(book.notes || '');
+        $('#editBookModal').data('loan', book.loan || null);
+        const loan = book.loan && !dateInput(book.loan.returned) ? book.loan : {};
+        $('#editBookLoanTo').val(loan.to || '');
+        $('#editBookLoanLent').val(dateInput(loan.lent));
+        $('#editBookLoanDue').val(dateInput(loan.due));
         
         // Show cover preview if available
         const preview = $('#editBookCoverPreview');
@@ -383,12 +427,45 @@ $(document).ready(function() {
             cover: book.cover || '',
             link: book.link || '',
             description: book.description || '',
-            notes: book.notes || ''
+            notes: book.notes || '',
+            loan: readLoan()
         });
         
         $('#editBookModal').modal('show');
     }
     
+    // readLoan returns the loan entered in the edit form. Clearing "Lent To"
+    // marks the book's current loan as returned today, so it stays on the
+    // calendar.
+    function readLoan() {
+        const previous = $('#editBookModal').data('loan');
+        const to = $('#editBookLoanTo').val().trim();
+        if (!to) {
+            if (!previous) {
+                return null;
+            }
+            const returned = dateInput(previous.returned) || new Date().toISOString().slice(0, 10);
+            return Object.assign({}, previous, { returned: returned + 'T00:00:00Z' });
+        }
+        const loan = { to: to };
+        const lent = $('#editBookLoanLent').val();
+        const due = $('#editBookLoanDue').val();
+        loan.lent = (lent || new Date().toISOString().slice(0, 10)) + 'T00:00:00Z';
+        if (due) {
+            loan.due = due + 'T00:00:00Z';
+        }
+        return loan;
+    }
+    
+    // dateInput formats a JSON date for a date input, leaving out Go's zero
+    // time.
+    function dateInput(value) {
+        if (!value || value.startsWith('0001-')) {
+            return '';
+        }
+        return value.slice(0, 10);
+    }
+    
     function saveBookChanges() {
         const bookData = {
             id: $('#editBookId').val(),
@@ -397,13 +474,14 @@ $(document).ready(function() {
             isbn: $('#editBookISBN').val(),
             type: $('#editBookType').val() || [],
             status: $('#editBookStatus').val(),
-            rating: parseInt($('#editBookRating').val()) || 0,
+            rating: parseFloat($('#editBookRating').val()) || 0,
             genre: $('#editBookGenre').val(),
             pages: parseInt($('#editBookPages').val()) || 0,
             cover: $('#editBookCover').val(),
             link: $('#editBookLink').val(),
             description: $('#editBookDescription').val(),
-            notes: $('#editBookNotes').val()
+            notes: $('#editBookNotes').val(),
+            loan: readLoan()
         };
         
         // Validate required fields
@@ -429,10 +507,11 @@ $(document).ready(function() {
                 $('#editBookModal').modal('hide');
                 $('#editBookForm')[0].reset();
                 $('#editBookModal').removeData('original-book');
+            $('#editBookModal').removeData('loan');
                 loadBooks();
             },
-            error: function() {
-                showToast('Failed to update book', 'error');
+            error: function(xhr) {
+                showToast(xhr.status === 400 && xhr.responseText ? xhr.responseText : 'Failed to update book', 'error');
             }
         });
     }
@@ -451,7 +530,7 @@ $(document).ready(function() {
             $('#editBookType').val([originalData.type]);
         }
         $('#editBookStatus').val(originalData.status);
-        $('#editBookRating').val(originalData.rating);
+        setRatingOption('#editBookRating', originalData.rating);
         $('#editBookGenre').val(originalData.genre);
         $('#editBookPages').val(originalData.pages);
         $('#editBookCover').val(originalData.cover);
@@ -525,7 +604,7 @@ $(document).ready(function() {
     }
     
     function loadStats() {
-        $.getJSON('/books/stats', function(data) {
+        $.getJSON(staticSite ? 'stats.json' : '/books/stats', function(data) {
             renderStats(data);
         }).fail(function() {
             showError('Failed to load statistics');
@@ -595,6 +674,24 @@ $(document).ready(function() {
         showToast(message, 'error');
     }
     
+    // Books without a cover get one generated from their title, author and genre
+    function bookCover(book, size) {
+        if (!book.cover || /via\.placeholder\.com/.test(book.cover)) {
+            const params = { title: book.name || '' };
+            if (book.author) params.author = book.author;
+            if (book.genre) params.genre = book.genre;
+            return '/covers/placeholder.svg?' + $.param(params);
+        }
+        return coverThumbnail(book.cover, size);
+    }
+    
+    // Stored covers have thumbnails next to them: /covers/<hash>-<size>.jpg,
+    // or covers/<hash>-<size>.jpg in a static build
+    function coverThumbnail(cover, size) {
+        const match = /^(\/?covers\/)([0-9a-f]{64})\.[a-z]+$/.exec(cover || '');
+        return match ? match[1] + match[2] + '-' + size + '.jpg' : cover;
+    }
+    
     function showToast(message, type = 'info') {
         const toastClass = type === 'error' ? 'bg-danger' : type === 'success' ? 'bg-success' : 'bg-info';
         const toast = $(`
@@ -624,6 +721,68 @@ $(document).ready(function() {
         };
     }
     
+    // Populate form fields with looked up book data
+    function fillBookForm(book, formType) {
+        if (formType === 'add') {
+            $('#bookTitle').val(book.title || '');
+            $('#bookAuthor').val(book.author || '');
+            $('#bookGenre').val(book.genre || '');
+            $('#bookPages').val(book.pages || '');
+            $('#bookCover').val(book.cover || '');
+            $('#bookDescription').val(book.description || '');
+        } else {
+            $('#editBookTitle').val(book.title || '');
+            $('#editBookAuthor').val(book.author || '');
+            $('#editBookGenre').val(book.genre || '');
+            $('#editBookPages').val(book.pages || '');
+            $('#editBookCover').val(book.cover || '');
+            $('#editBookDescription').val(book.description || '');
+            
+            // Update cover preview
+            const preview = $('#editBookCoverPreview');
+            if (book.cover && book.cover.trim()) {
+                preview.attr('src', book.cover).show();
+            } else {
+                preview.hide();
+            }
+        }
+    }
+    
+    function scanBarcode(file) {
+        const button = $('#scanBarcode');
+        const originalText = button.html();
+        button.html('<i class="fas fa-spinner fa-spin"></i>').prop('disabled', true);
+        
+        const formData = new FormData();
+        formData.append('image', file);
+        
+        $.ajax({
+            url: '/books/scan',
+            method: 'POST',
+            data: formData,
+            processData: false,
+            contentType: false,
+            success: function(data) {
+                if (data.isbn) {
+                    $('#bookISBN').val(data.isbn);
+                }
+                if (data.success && data.book) {
+                    fillBookForm(data.book, 'add');
+                    showToast('Book details populated successfully!', 'success');
+                } else {
+                    showToast(data.message || 'No barcode found', 'error');
+                }
+            },
+            error: function(xhr) {
+                showToast(xhr.status === 400 && xhr.responseText ? xhr.responseText : 'Failed to scan barcode', 'error');
+            },
+            complete: function() {
+                button.html(originalText).prop('disabled', false);
+                $('#scanBarcodeInput').val('');
+            }
+        });
+    }
+    
     function lookupBookByISBN(isbn, formType) {
         // Show loading state
         const button = formType === 'add' ? $('#lookupISBN') : $('#editLookupISBN');
@@ -637,31 +796,7 @@ $(document).ready(function() {
             data: JSON.stringify({ isbn: isbn }),
             success: function(data) {
                 if (data.success && data.book) {
-                    // Populate form fields with book data
-                    if (formType === 'add') {
-                        $('#bookTitle').val(data.book.title || '');
-                        $('#bookAuthor').val(data.book.author || '');
-                        $('#bookGenre').val(data.book.genre || '');
-                        $('#bookPages').val(data.book.pages || '');
-                        $('#bookCover').val(data.book.cover || '');
-                        $('#bookDescription').val(data.book.description || '');
-                    } else {
-                        $('#editBookTitle').val(data.book.title || '');
-                        $('#editBookAuthor').val(data.book.author || '');
-                        $('#editBookGenre').val(data.book.genre || '');
-                        $('#editBookPages').val(data.book.pages || '');
-                        $('#editBookCover').val(data.book.cover || '');
-                        $('#editBookDescription').val(data.book.description || '');
-                        
-                        // Update cover preview
-                        const preview = $('#editBookCoverPreview');
-                        if (data.book.cover && data.book.cover.trim()) {
-                            preview.attr('src', data.book.cover).show();
-                        } else {
-                            preview.hide();
-                        }
-                    }
-                    
+                    fillBookForm(data.book, formType);
                     showToast('Book details populated successfully!', 'success');
                 } else {
                     showToast(data.message || 'Book not found', 'error');
@@ -676,4 +811,69 @@ $(document).ready(function() {
             }
         });
     }
+    
+    function searchBookCandidates(title, author) {
+        const button = $('#lookupISBN');
+        const originalText = button.html();
+        const list = $('#lookupCandidates');
+        button.html('<i class="fas fa-spinner fa-spin"></i> Searching...').prop('disabled', true);
+        
+        $.ajax({
+            url: '/books/lookup',
+            method: 'POST',
+            contentType: 'application/json',
+            data: JSON.stringify({ title: title, author: author }),
+            success: function(data) {
+                list.empty();
+                if (!data.success) {
+                    list.hide();
+                    showToast(data.message || 'Failed to search for books', 'error');
+                    return;
+                }
+                if (!data.candidates || data.candidates.length === 0) {
+                    list.hide();
+                    showToast('No matching books found', 'error');
+                    return;
+                }
+                
+                data.candidates.forEach(function(candidate) {
+                    const item = $('<button type="button" class="list-group-item list-group-item-action d-flex align-items-center"></button>');
+                    if (candidate.cover) {
+                        item.append($('<img class="me-3" style="width: 40px; height: 60px; object-fit: cover;">').attr('src', candidate.cover));
+                    }
+                    const details = $('<div></div>');
+                    details.append($('<div class="fw-bold"></div>').text(candidate.title));
+                    const editions = candidate.edition_count + (candidate.edition_count === 1 ? ' edition' : ' editions');
+                    details.append($('<small class="text-muted"></small>').text([candidate.author, candidate.year, editions].filter(Boolean).join(' · ')));
+                    item.append(details);
+                    item.on('click', function() {
+                        pickBookCandidate(candidate);
+                    });
+                    list.append(item);
+                });
+                list.show();
+            },
+            error: function() {
+                showToast('Failed to search for books', 'error');
+            },
+            complete: function() {
+                button.html(originalText).prop('disabled', false);
+            }
+        });
+    }
+    
+    function pickBookCandidate(candidate) {
+        $('#lookupCandidates').empty().hide();
+        $('#bookTitle').val(candidate.title || '');
+        $('#bookAuthor').val(candidate.author || '');
+        $('#bookCover').val(candidate.cover || '');
+        
+        // Fill in the rest of the form from the first edition with an ISBN
+        if (candidate.isbns && candidate.isbns.length > 0) {
+            $('#bookISBN').val(candidate.isbns[0]);
+            lookupBookByISBN(candidate.isbns[0], 'add');
+        } else {
+            showToast('Book details populated successfully!', 'success');
+        }
+    }
 });
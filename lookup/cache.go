@@ -0,0 +1,263 @@
+package lookup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+)
+
+// Cache keeps catalogue answers on disk, one JSON file per key, so repeated
+// lookups are instant and keep working offline. Books a catalogue does not
+// know are cached too, for a shorter time. An expired answer is still used
+// when refreshing it fails.
+type Cache struct {
+	Dir string
+	// TTL is how long found entries stay fresh; NegativeTTL is how long a
+	// not-found answer does.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	mu                        sync.Mutex
+	hits, misses, stale, errs int64
+}
+
+// NewCache returns a cache in dir that keeps answers for 30 days and
+// not-found answers for a day.
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir, TTL: 30 * 24 * time.Hour, NegativeTTL: 24 * time.Hour}
+}
+
+// CacheStats describes the cache's contents and its use since start.
+type CacheStats struct {
+	Entries  int   `json:"entries"`
+	Negative int   `json:"negative"`
+	Expired  int   `json:"expired"`
+	Bytes    int64 `json:"bytes"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+	// StaleHits counts expired answers served because refreshing failed.
+	StaleHits int64 `json:"stale_hits"`
+	Errors    int64 `json:"errors"`
+}
+
+// PurgeOptions selects cache entries to remove. The zero value selects
+// everything.
+type PurgeOptions struct {
+	ISBN        string // entries for this ISBN from every provider
+	AuthorKey   string // the entry for this author key
+	ExpiredOnly bool
+}
+
+type cacheEntry struct {
+	Key     string          `json:"key"`
+	Stored  time.Time       `json:"stored"`
+	Expires time.Time       `json:"expires"`
+	Found   bool            `json:"found"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+func isbnKey(provider, code string) string {
+	return "isbn:" + provider + ":" + isbn.Normalize(code)
+}
+
+func authorKey(key string) string {
+	return "author:" + key
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.Dir, name[:2], name+".json")
+}
+
+// load returns the entry stored under key, or nil.
+func (c *Cache) load(key string) *cacheEntry {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil || e.Key != key {
+		return nil
+	}
+	return &e
+}
+
+// store saves v under key. A nil v records that the catalogue does not know
+// the key.
+func (c *Cache) store(key string, v interface{}) error {
+	now := time.Now()
+	e := cacheEntry{Key: key, Stored: now, Expires: now.Add(c.NegativeTTL)}
+	if v != nil {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		e.Found = true
+		e.Value = value
+		e.Expires = now.Add(c.TTL)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fetch returns the answer cached under key, calling fn on a miss or when
+// the entry has expired. fn reports a not-found answer by returning false.
+func (c *Cache) fetch(key string, v interface{}, fn func() (interface{}, bool, error)) (bool, error) {
+	cached := c.load(key)
+	if cached != nil && time.Now().Before(cached.Expires) {
+		atomic.AddInt64(&c.hits, 1)
+		return cached.Found, c.decode(cached, v)
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	value, found, err := fn()
+	if err != nil {
+		atomic.AddInt64(&c.errs, 1)
+		if cached != nil {
+			atomic.AddInt64(&c.stale, 1)
+			return cached.Found, c.decode(cached, v)
+		}
+		return false, err
+	}
+
+	if !found {
+		value = nil
+	}
+	if err := c.store(key, value); err != nil {
+		atomic.AddInt64(&c.errs, 1)
+	}
+	if !found {
+		return false, nil
+	}
+
+	// Round-trip through JSON so that hits and misses return the same thing.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (c *Cache) decode(e *cacheEntry, v interface{}) error {
+	if !e.Found {
+		return nil
+	}
+	return json.Unmarshal(e.Value, v)
+}
+
+// Stats counts the entries on disk and reports hit rates since start.
+func (c *Cache) Stats() (CacheStats, error) {
+	stats := CacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		StaleHits: atomic.LoadInt64(&c.stale),
+		Errors:    atomic.LoadInt64(&c.errs),
+	}
+	now := time.Now()
+	err := c.walk(func(path string, e cacheEntry, size int64) error {
+		stats.Entries++
+		stats.Bytes += size
+		if !e.Found {
+			stats.Negative++
+		}
+		if !now.Before(e.Expires) {
+			stats.Expired++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Purge removes the selected entries and returns how many it removed.
+func (c *Cache) Purge(opts PurgeOptions) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	code := isbn.Normalize(opts.ISBN)
+	now := time.Now()
+	removed := 0
+	err := c.walk(func(path string, e cacheEntry, size int64) error {
+		switch {
+		case opts.ISBN != "" && !(strings.HasPrefix(e.Key, "isbn:") && strings.HasSuffix(e.Key, ":"+code)):
+			return nil
+		case opts.AuthorKey != "" && e.Key != authorKey(opts.AuthorKey):
+			return nil
+		case opts.ExpiredOnly && now.Before(e.Expires):
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// walk calls fn for every readable entry. A missing directory is empty.
+func (c *Cache) walk(fn func(path string, e cacheEntry, size int64) error) error {
+	err := filepath.WalkDir(c.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var e cacheEntry
+		if json.Unmarshal(data, &e) != nil {
+			return nil
+		}
+		return fn(path, e, int64(len(data)))
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Cached wraps a provider so its answers are kept in Cache, keyed by
+// provider name and normalized ISBN.
+type Cached struct {
+	Provider MetadataProvider
+	Cache    *Cache
+}
+
+func (c Cached) Name() string { return c.Provider.Name() }
+
+func (c Cached) LookupISBN(ctx context.Context, isbn string) (*Result, error) {
+	var result Result
+	found, err := c.Cache.fetch(isbnKey(c.Provider.Name(), isbn), &result, func() (interface{}, bool, error) {
+		r, err := c.Provider.LookupISBN(ctx, isbn)
+		return r, r != nil, err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &result, nil
+}
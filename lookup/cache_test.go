@@ -0,0 +1,100 @@
+package lookup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup/lookuptest"
+)
+
+func newCachedFake(t *testing.T) (*lookuptest.OpenLibrary, Cached) {
+	t.Helper()
+	fake := lookuptest.NewOpenLibrary()
+	t.Cleanup(fake.Close)
+	fake.AddEdition("9780547928227", lookuptest.Edition{Title: "The Hobbit", NumberOfPages: 300})
+
+	provider := OpenLibrary{BaseURL: fake.URL, Client: &Client{}}
+	return fake, Cached{Provider: provider, Cache: NewCache(t.TempDir())}
+}
+
+func TestCachedLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		lookups   []string
+		wantTitle string
+		wantCalls int
+	}{
+		{name: "repeated", lookups: []string{"9780547928227", "9780547928227"}, wantTitle: "The Hobbit", wantCalls: 1},
+		{name: "either ISBN form", lookups: []string{"9780547928227", "054792822X", "978-0-547-92822-7"}, wantTitle: "The Hobbit", wantCalls: 1},
+		{name: "not found is cached", lookups: []string{"9780306406157", "9780306406157"}, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, cached := newCachedFake(t)
+
+			for _, code := range tt.lookups {
+				result, err := cached.LookupISBN(context.Background(), code)
+				if err != nil {
+					t.Fatalf("LookupISBN(%q): %v", code, err)
+				}
+				var title string
+				if result != nil {
+					title = result.Title
+				}
+				if title != tt.wantTitle {
+					t.Errorf("LookupISBN(%q) title = %q, want %q", code, title, tt.wantTitle)
+				}
+			}
+
+			if calls := len(fake.Requests()); calls != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedServesStaleOnError(t *testing.T) {
+	fake, cached := newCachedFake(t)
+	cached.Cache.TTL = -time.Second // every entry is expired at once
+
+	if _, err := cached.LookupISBN(context.Background(), "9780547928227"); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Fail("", 503)
+	result, err := cached.LookupISBN(context.Background(), "9780547928227")
+	if err != nil {
+		t.Fatalf("LookupISBN with the catalogue down: %v", err)
+	}
+	if result == nil || result.Title != "The Hobbit" {
+		t.Errorf("LookupISBN with the catalogue down = %+v, want the cached edition", result)
+	}
+
+	stats, err := cached.Cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.StaleHits != 1 || stats.Entries != 1 || stats.Expired != 1 {
+		t.Errorf("Stats = %+v, want one expired entry served stale once", stats)
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	_, cached := newCachedFake(t)
+	for _, code := range []string{"9780547928227", "9780306406157"} {
+		if _, err := cached.LookupISBN(context.Background(), code); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := cached.Cache.Purge(PurgeOptions{ISBN: "054792822X"})
+	if err != nil || removed != 1 {
+		t.Fatalf("Purge by ISBN removed %d, %v, want 1", removed, err)
+	}
+	removed, err = cached.Cache.Purge(PurgeOptions{})
+	if err != nil || removed != 1 {
+		t.Fatalf("Purge of everything removed %d, %v, want 1", removed, err)
+	}
+}
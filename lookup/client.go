@@ -0,0 +1,153 @@
+package lookup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client fetches catalogue responses. It sets a User-Agent, bounds every
+// attempt with a timeout and retries rate-limited (429) and server error
+// (5xx) responses with exponential backoff, waiting at least as long as the
+// server's Retry-After header asks.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+	// Retries is the number of extra attempts after the first.
+	Retries int
+	// Backoff is the wait before the first retry; it doubles with every
+	// further retry up to MaxBackoff, which also caps Retry-After.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxBody, when positive, is the largest response body Get reads. A
+	// larger body is an error, and no more than MaxBody+1 bytes of it are
+	// read.
+	MaxBody int64
+}
+
+// DefaultUserAgent identifies the shelf to catalogue operators, as Open
+// Library asks API clients to do.
+const DefaultUserAgent = "book-list/1.0 (+https://github.com/rahutchinson/book-list)"
+
+// NewClient returns a client with a 10 second timeout per attempt and up to
+// three retries.
+func NewClient() *Client {
+	return &Client{
+		HTTP:       &http.Client{Timeout: 10 * time.Second},
+		UserAgent:  DefaultUserAgent,
+		Retries:    3,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// DefaultClient is used by providers without a Client.
+var DefaultClient = NewClient()
+
+// errNotFound is returned by Get for 404 responses.
+var errNotFound = errors.New("not found")
+
+// IsNotFound reports whether err is a 404 from a catalogue.
+func IsNotFound(err error) bool {
+	return errors.Is(err, errNotFound)
+}
+
+// StatusError is an unsuccessful response that was not retried, or that was
+// still failing after the last retry.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: API returned status %d", e.URL, e.StatusCode)
+}
+
+// Get fetches url and returns the body of a 200 response.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if c.UserAgent != "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			body, err := c.readBody(resp.Body)
+			resp.Body.Close()
+			if err == nil && c.MaxBody > 0 && int64(len(body)) > c.MaxBody {
+				return nil, fmt.Errorf("%s is larger than %d bytes", url, c.MaxBody)
+			}
+			return body, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errNotFound
+		}
+		if !retryable(resp.StatusCode) || attempt >= c.Retries {
+			return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+		}
+
+		wait := backoff
+		if after := retryAfter(resp.Header.Get("Retry-After"), time.Now()); after > wait {
+			wait = after
+		}
+		if c.MaxBackoff > 0 && wait > c.MaxBackoff {
+			wait = c.MaxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) readBody(r io.Reader) ([]byte, error) {
+	if c.MaxBody > 0 {
+		r = io.LimitReader(r, c.MaxBody+1)
+	}
+	return io.ReadAll(r)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date. It returns zero when the header is missing or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
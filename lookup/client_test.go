@@ -0,0 +1,130 @@
+package lookup
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup/lookuptest"
+)
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []int
+		retries   int
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "success", retries: 3, wantCalls: 1},
+		{name: "server errors", failures: []int{503, 500}, retries: 3, wantCalls: 3},
+		{name: "rate limited", failures: []int{429}, retries: 3, wantCalls: 2},
+		{name: "gives up", failures: []int{503, 503, 503}, retries: 2, wantErr: true, wantCalls: 3},
+		{name: "no retries", failures: []int{503}, retries: 0, wantErr: true, wantCalls: 1},
+		{name: "client error", failures: []int{400}, retries: 3, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := lookuptest.NewOpenLibrary()
+			defer fake.Close()
+			fake.AddEdition("9780547928227", lookuptest.Edition{Title: "The Hobbit"})
+			fake.Fail("", tt.failures...)
+
+			c := &Client{Retries: tt.retries, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+			_, err := c.Get(context.Background(), fake.URL+"/isbn/9780547928227.json")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get error = %v, want error %v", err, tt.wantErr)
+			}
+			if calls := len(fake.Requests()); calls != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClientNotFound(t *testing.T) {
+	fake := lookuptest.NewOpenLibrary()
+	defer fake.Close()
+
+	c := &Client{Retries: 3, Backoff: time.Millisecond}
+	_, err := c.Get(context.Background(), fake.URL+"/isbn/9780000000002.json")
+	if !IsNotFound(err) {
+		t.Errorf("Get error = %v, want not found", err)
+	}
+	if calls := len(fake.Requests()); calls != 1 {
+		t.Errorf("made %d requests, want 1", calls)
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		maxBackoff time.Duration
+		min, max   time.Duration
+	}{
+		{name: "waits as asked", retryAfter: "1", maxBackoff: 5 * time.Second, min: time.Second, max: 3 * time.Second},
+		{name: "capped by MaxBackoff", retryAfter: "120", maxBackoff: 20 * time.Millisecond, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := lookuptest.NewOpenLibrary()
+			defer fake.Close()
+			fake.AddEdition("9780547928227", lookuptest.Edition{Title: "The Hobbit"})
+			fake.Fail(tt.retryAfter, http.StatusTooManyRequests)
+
+			c := &Client{Retries: 1, Backoff: time.Millisecond, MaxBackoff: tt.maxBackoff}
+			start := time.Now()
+			if _, err := c.Get(context.Background(), fake.URL+"/isbn/9780547928227.json"); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("took %v, want between %v and %v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestClientMaxBody(t *testing.T) {
+	fake := lookuptest.NewOpenLibrary()
+	defer fake.Close()
+	fake.AddEdition("9780547928227", lookuptest.Edition{Title: "The Hobbit"})
+	url := fake.URL + "/isbn/9780547928227.json"
+
+	body, err := (&Client{}).Get(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&Client{MaxBody: int64(len(body))}).Get(context.Background(), url); err != nil {
+		t.Errorf("body of exactly MaxBody bytes: %v", err)
+	}
+	if _, err := (&Client{MaxBody: int64(len(body)) - 1}).Get(context.Background(), url); err == nil {
+		t.Error("body over MaxBody was accepted")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+
+	for _, tt := range tests {
+		if got := retryAfter(tt.value, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
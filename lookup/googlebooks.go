@@ -0,0 +1,77 @@
+package lookup
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// GoogleBooks looks books up through the Google Books API. An API key is
+// optional for low volumes.
+type GoogleBooks struct {
+	BaseURL string // default https://www.googleapis.com/books/v1
+	APIKey  string
+	Client  *Client
+}
+
+func (g GoogleBooks) Name() string { return "googlebooks" }
+
+type googleVolumes struct {
+	Items []struct {
+		VolumeInfo struct {
+			Title         string   `json:"title"`
+			Subtitle      string   `json:"subtitle"`
+			Authors       []string `json:"authors"`
+			Publisher     string   `json:"publisher"`
+			PublishedDate string   `json:"publishedDate"`
+			Description   string   `json:"description"`
+			PageCount     int      `json:"pageCount"`
+			Categories    []string `json:"categories"`
+			ImageLinks    struct {
+				Thumbnail      string `json:"thumbnail"`
+				SmallThumbnail string `json:"smallThumbnail"`
+			} `json:"imageLinks"`
+		} `json:"volumeInfo"`
+	} `json:"items"`
+}
+
+func (g GoogleBooks) LookupISBN(ctx context.Context, isbn string) (*Result, error) {
+	query := url.Values{"q": {"isbn:" + isbn}}
+	if g.APIKey != "" {
+		query.Set("key", g.APIKey)
+	}
+
+	var volumes googleVolumes
+	err := getJSON(ctx, g.Client, trimBase(g.BaseURL, "https://www.googleapis.com/books/v1")+"/volumes?"+query.Encode(), &volumes)
+	if IsNotFound(err) || (err == nil && len(volumes.Items) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := volumes.Items[0].VolumeInfo
+	result := &Result{
+		ISBN:        isbn,
+		Title:       info.Title,
+		Pages:       info.PageCount,
+		Description: info.Description,
+		Publisher:   info.Publisher,
+		Published:   parseDate(info.PublishedDate),
+		Sources:     []string{g.Name()},
+	}
+	if len(info.Authors) > 0 {
+		result.Author = info.Authors[0]
+	}
+	if len(info.Categories) > 0 {
+		result.Genre = info.Categories[0]
+	}
+
+	cover := info.ImageLinks.Thumbnail
+	if cover == "" {
+		cover = info.ImageLinks.SmallThumbnail
+	}
+	result.Cover = strings.Replace(cover, "http://", "https://", 1)
+
+	return result, nil
+}
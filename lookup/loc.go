@@ -0,0 +1,140 @@
+package lookup
+
+import (
+	"context"
+	"encoding/xml"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LibraryOfCongress looks books up in the Library of Congress catalogue
+// through its SRU search service, asking for MODS records.
+type LibraryOfCongress struct {
+	BaseURL string // default http://lx2.loc.gov:210/LCDB
+	Client  *Client
+}
+
+func (l LibraryOfCongress) Name() string { return "loc" }
+
+type sruResponse struct {
+	Records []struct {
+		MODS modsRecord `xml:"recordData>mods"`
+	} `xml:"records>record"`
+}
+
+type modsRecord struct {
+	TitleInfo []struct {
+		Type     string `xml:"type,attr"`
+		NonSort  string `xml:"nonSort"`
+		Title    string `xml:"title"`
+		SubTitle string `xml:"subTitle"`
+	} `xml:"titleInfo"`
+	Names []struct {
+		Type      string `xml:"type,attr"`
+		Usage     string `xml:"usage,attr"`
+		NameParts []struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"namePart"`
+	} `xml:"name"`
+	OriginInfo []struct {
+		Publisher  []string `xml:"publisher"`
+		DateIssued []string `xml:"dateIssued"`
+	} `xml:"originInfo"`
+	Extent   []string `xml:"physicalDescription>extent"`
+	Abstract []string `xml:"abstract"`
+	Topics   []string `xml:"subject>topic"`
+}
+
+func (l LibraryOfCongress) LookupISBN(ctx context.Context, isbn string) (*Result, error) {
+	query := url.Values{
+		"version":        {"1.1"},
+		"operation":      {"searchRetrieve"},
+		"query":          {"bath.isbn=" + isbn},
+		"maximumRecords": {"1"},
+		"recordSchema":   {"mods"},
+	}
+	body, err := get(ctx, l.Client, trimBase(l.BaseURL, "http://lx2.loc.gov:210/LCDB")+"?"+query.Encode())
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var response sruResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Records) == 0 {
+		return nil, nil
+	}
+
+	mods := response.Records[0].MODS
+	result := &Result{ISBN: isbn, Sources: []string{l.Name()}}
+
+	for _, t := range mods.TitleInfo {
+		if t.Type == "" {
+			result.Title = strings.TrimRight(strings.TrimSpace(t.NonSort+t.Title), " /:;,.")
+			break
+		}
+	}
+
+	for _, n := range mods.Names {
+		if n.Type != "personal" {
+			continue
+		}
+		for _, part := range n.NameParts {
+			if part.Type == "" {
+				result.Author = invertName(part.Value)
+				break
+			}
+		}
+		if result.Author != "" && n.Usage == "primary" {
+			break
+		}
+	}
+
+	for _, origin := range mods.OriginInfo {
+		if result.Publisher == "" && len(origin.Publisher) > 0 {
+			result.Publisher = strings.TrimRight(strings.TrimSpace(origin.Publisher[0]), " ,:;")
+		}
+		for _, issued := range origin.DateIssued {
+			if result.Published.IsZero() {
+				result.Published = parseDate(issued)
+			}
+		}
+	}
+
+	for _, extent := range mods.Extent {
+		if m := pagesPattern.FindStringSubmatch(extent); m != nil {
+			result.Pages, _ = strconv.Atoi(m[1])
+			break
+		}
+	}
+	if len(mods.Abstract) > 0 {
+		result.Description = strings.TrimSpace(mods.Abstract[0])
+	}
+	if len(mods.Topics) > 0 {
+		result.Genre = strings.TrimRight(strings.TrimSpace(mods.Topics[0]), ".")
+	}
+
+	return result, nil
+}
+
+// pagesPattern finds the page count in a physical description such as
+// "xii, 279 p. ; 24 cm.".
+var pagesPattern = regexp.MustCompile(`(\d+)\s*(?:p\b|pages)`)
+
+// invertName turns a catalogue heading such as "Tolkien, J. R. R.," into
+// "J. R. R. Tolkien".
+func invertName(heading string) string {
+	heading = strings.TrimRight(strings.TrimSpace(heading), " ,")
+	family, given, ok := strings.Cut(heading, ",")
+	if !ok {
+		return heading
+	}
+	return strings.TrimSpace(given) + " " + strings.TrimSpace(family)
+}
@@ -0,0 +1,157 @@
+// Package lookup finds book metadata by ISBN from online catalogues.
+//
+// Each catalogue is a MetadataProvider. Providers are combined with Multi,
+// which asks all of them and merges their answers by priority, so a field
+// missing from one catalogue can be filled in by the next.
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Result is the metadata a provider found for a book. Empty fields are
+// unknown.
+type Result struct {
+	ISBN        string    `json:"isbn"`
+	Title       string    `json:"title,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Pages       int       `json:"pages,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Genre       string    `json:"genre,omitempty"`
+	Cover       string    `json:"cover,omitempty"`
+	Publisher   string    `json:"publisher,omitempty"`
+	Published   time.Time `json:"published"`
+	// Sources names the providers that contributed to the result, in
+	// priority order.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// MetadataProvider looks books up in a single catalogue.
+type MetadataProvider interface {
+	// Name identifies the provider in Result.Sources and in errors.
+	Name() string
+	// LookupISBN returns nil and no error when the catalogue does not know
+	// the book.
+	LookupISBN(ctx context.Context, isbn string) (*Result, error)
+}
+
+// Multi asks every provider at once and merges their results, with earlier
+// providers taking priority.
+type Multi []MetadataProvider
+
+func (m Multi) Name() string {
+	names := make([]string, len(m))
+	for i, p := range m {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// LookupISBN returns the merged result of every provider that found the
+// book. Provider errors are only returned when no provider found it.
+func (m Multi) LookupISBN(ctx context.Context, isbn string) (*Result, error) {
+	results := make([]*Result, len(m))
+	errs := make([]error, len(m))
+
+	done := make(chan struct{})
+	for i, p := range m {
+		go func(i int, p MetadataProvider) {
+			defer func() { done <- struct{}{} }()
+			results[i], errs[i] = p.LookupISBN(ctx, isbn)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", p.Name(), errs[i])
+			}
+		}(i, p)
+	}
+	for range m {
+		<-done
+	}
+
+	if merged := Merge(results...); merged != nil {
+		return merged, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Merge combines results in priority order: each field comes from the first
+// result that has it. Nil results are skipped, and Merge returns nil when
+// all of them are nil.
+func Merge(results ...*Result) *Result {
+	var merged *Result
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if merged == nil {
+			copied := *r
+			copied.Sources = append([]string(nil), r.Sources...)
+			merged = &copied
+			continue
+		}
+
+		contributed := false
+		fill := func(dst *string, src string) {
+			if *dst == "" && src != "" {
+				*dst = src
+				contributed = true
+			}
+		}
+		fill(&merged.ISBN, r.ISBN)
+		fill(&merged.Title, r.Title)
+		fill(&merged.Author, r.Author)
+		fill(&merged.Description, r.Description)
+		fill(&merged.Genre, r.Genre)
+		fill(&merged.Cover, r.Cover)
+		fill(&merged.Publisher, r.Publisher)
+		if merged.Pages == 0 && r.Pages != 0 {
+			merged.Pages = r.Pages
+			contributed = true
+		}
+		if merged.Published.IsZero() && !r.Published.IsZero() {
+			merged.Published = r.Published
+			contributed = true
+		}
+		if contributed {
+			merged.Sources = append(merged.Sources, r.Sources...)
+		}
+	}
+	return merged
+}
+
+// get fetches url with client, or DefaultClient when client is nil.
+func get(ctx context.Context, client *Client, url string) ([]byte, error) {
+	if client == nil {
+		client = DefaultClient
+	}
+	return client.Get(ctx, url)
+}
+
+// getJSON fetches url and decodes a 200 response into v.
+func getJSON(ctx context.Context, client *Client, url string, v interface{}) error {
+	body, err := get(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// parseDate reads the publication dates catalogues use, from a bare year to
+// a full date. It returns the zero time when none match.
+func parseDate(value string) time.Time {
+	value = strings.Trim(strings.TrimSpace(value), "[].c©")
+	return parse.Date(value, "2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "January 2006", "Jan 2006", "2006-01", "2006")
+}
+
+func trimBase(base, fallback string) string {
+	if base == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(base, "/")
+}
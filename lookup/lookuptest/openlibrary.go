@@ -0,0 +1,190 @@
+// Package lookuptest provides fake catalogue servers for exercising the
+// lookup package without network access.
+package lookuptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Edition is an Open Library edition record as served by the fake.
+type Edition struct {
+	Title         string   `json:"title"`
+	Authors       []Ref    `json:"authors,omitempty"`
+	NumberOfPages int      `json:"number_of_pages,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Subjects      []string `json:"subjects,omitempty"`
+	Publishers    []string `json:"publishers,omitempty"`
+	PublishDate   string   `json:"publish_date,omitempty"`
+	Covers        []int    `json:"covers,omitempty"`
+}
+
+// Work is an Open Library search result as served by the fake.
+type Work struct {
+	Key              string   `json:"key"`
+	Title            string   `json:"title"`
+	AuthorName       []string `json:"author_name,omitempty"`
+	FirstPublishYear int      `json:"first_publish_year,omitempty"`
+	CoverI           int      `json:"cover_i,omitempty"`
+	EditionCount     int      `json:"edition_count,omitempty"`
+	ISBN             []string `json:"isbn,omitempty"`
+}
+
+// Ref references an author record by key, e.g. "/authors/OL21594A".
+type Ref struct {
+	Key string `json:"key"`
+}
+
+// OpenLibrary is a fake Open Library serving /isbn/{isbn}.json,
+// /authors/{id}.json and /search.json from memory. Unknown records are 404s;
+// a search returns the works whose title and author contain the title and
+// author asked for, ignoring case, in the order they were added. Point
+// lookup.OpenLibrary's BaseURL at URL to use it:
+//
+//	fake := lookuptest.NewOpenLibrary()
+//	defer fake.Close()
+//	fake.AddEdition("9780547928227", lookuptest.Edition{Title: "The Hobbit"})
+//	provider := lookup.OpenLibrary{BaseURL: fake.URL}
+type OpenLibrary struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	editions   map[string]Edition
+	authors    map[string]string
+	works      []Work
+	failures   []int
+	retryAfter string
+	requests   []*http.Request
+}
+
+// NewOpenLibrary starts a fake Open Library. Close it when done.
+func NewOpenLibrary() *OpenLibrary {
+	f := &OpenLibrary{
+		editions: make(map[string]Edition),
+		authors:  make(map[string]string),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// AddEdition makes an edition available by ISBN.
+func (f *OpenLibrary) AddEdition(isbn string, e Edition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.editions[isbn] = e
+}
+
+// AddAuthor makes an author record available by key.
+func (f *OpenLibrary) AddAuthor(key, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authors[key] = name
+}
+
+// AddWork makes a work available to searches.
+func (f *OpenLibrary) AddWork(w Work) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.works = append(f.works, w)
+}
+
+// Fail makes the next requests fail with the given status codes, one code
+// per request, before normal responses resume. retryAfter, when not empty,
+// is sent as the Retry-After header of those failures.
+func (f *OpenLibrary) Fail(retryAfter string, statuses ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, statuses...)
+	f.retryAfter = retryAfter
+}
+
+// Requests returns the requests received so far.
+func (f *OpenLibrary) Requests() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*http.Request(nil), f.requests...)
+}
+
+func (f *OpenLibrary) serve(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	if len(f.failures) > 0 {
+		status := f.failures[0]
+		f.failures = f.failures[1:]
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	path := req.URL.Path
+	if !strings.HasSuffix(path, ".json") {
+		http.NotFound(w, req)
+		return
+	}
+	path = strings.TrimSuffix(path, ".json")
+
+	var record interface{}
+	switch {
+	case strings.HasPrefix(path, "/isbn/"):
+		e, ok := f.editions[strings.TrimPrefix(path, "/isbn/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		record = e
+	case strings.HasPrefix(path, "/authors/"):
+		name, ok := f.authors[path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		record = map[string]string{"key": path, "name": name}
+	case path == "/search":
+		record = map[string][]Work{"docs": f.search(req.URL.Query())}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	body, err := json.Marshal(record)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+func (f *OpenLibrary) search(query url.Values) []Work {
+	title := strings.ToLower(query.Get("title"))
+	author := strings.ToLower(query.Get("author"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	docs := []Work{}
+	for _, w := range f.works {
+		if !strings.Contains(strings.ToLower(w.Title), title) {
+			continue
+		}
+		if author != "" && !strings.Contains(strings.ToLower(strings.Join(w.AuthorName, "\n")), author) {
+			continue
+		}
+		docs = append(docs, w)
+		if len(docs) == limit {
+			break
+		}
+	}
+	return docs
+}
@@ -0,0 +1,162 @@
+package lookup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// OpenLibrary looks books up through the Open Library API.
+type OpenLibrary struct {
+	BaseURL string // default https://openlibrary.org
+	// CoversURL serves cover images by ID. Default
+	// https://covers.openlibrary.org.
+	CoversURL string
+	Client    *Client
+	// Cache, when set, keeps resolved author names by author key.
+	Cache *Cache
+}
+
+func (o OpenLibrary) Name() string { return "openlibrary" }
+
+func (o OpenLibrary) base() string {
+	return trimBase(o.BaseURL, "https://openlibrary.org")
+}
+
+// openLibraryEdition is the part of an edition record the lookup uses.
+type openLibraryEdition struct {
+	Title         string `json:"title"`
+	Subtitle      string `json:"subtitle"`
+	NumberOfPages int    `json:"number_of_pages"`
+	Authors       []struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	} `json:"authors"`
+	Author      string          `json:"author"`
+	Description json.RawMessage `json:"description"`
+	Subjects    []string        `json:"subjects"`
+	Publishers  []string        `json:"publishers"`
+	PublishDate string          `json:"publish_date"`
+	Covers      []int           `json:"covers"`
+	Cover       struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+	CoverID int `json:"cover_id"`
+}
+
+func (o OpenLibrary) LookupISBN(ctx context.Context, isbn string) (*Result, error) {
+	var edition openLibraryEdition
+	err := getJSON(ctx, o.Client, fmt.Sprintf("%s/isbn/%s.json", o.base(), url.PathEscape(isbn)), &edition)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		ISBN:        isbn,
+		Title:       edition.Title,
+		Pages:       edition.NumberOfPages,
+		Description: textValue(edition.Description),
+		Published:   parseDate(edition.PublishDate),
+		Sources:     []string{o.Name()},
+	}
+
+	// Edition records usually only reference their authors by key.
+	if len(edition.Authors) > 0 {
+		author := edition.Authors[0]
+		if author.Key != "" {
+			if name, err := o.AuthorName(ctx, author.Key); err == nil {
+				result.Author = name
+			}
+		}
+		if result.Author == "" {
+			result.Author = author.Name
+		}
+	}
+	if result.Author == "" {
+		result.Author = edition.Author
+	}
+
+	if len(edition.Subjects) > 0 {
+		result.Genre = edition.Subjects[0]
+	}
+	if len(edition.Publishers) > 0 {
+		result.Publisher = edition.Publishers[0]
+	}
+
+	switch {
+	case edition.Cover.Large != "":
+		result.Cover = edition.Cover.Large
+	case edition.Cover.Medium != "":
+		result.Cover = edition.Cover.Medium
+	case edition.Cover.Small != "":
+		result.Cover = edition.Cover.Small
+	case edition.CoverID > 0:
+		result.Cover = o.coverURL(edition.CoverID)
+	case len(edition.Covers) > 0 && edition.Covers[0] > 0:
+		result.Cover = o.coverURL(edition.Covers[0])
+	}
+
+	return result, nil
+}
+
+// AuthorName resolves an author key such as "/authors/OL21594A".
+func (o OpenLibrary) AuthorName(ctx context.Context, key string) (string, error) {
+	if o.Cache == nil {
+		return o.authorName(ctx, key)
+	}
+
+	var name string
+	found, err := o.Cache.fetch(authorKey(key), &name, func() (interface{}, bool, error) {
+		name, err := o.authorName(ctx, key)
+		if IsNotFound(err) {
+			return nil, false, nil
+		}
+		return name, err == nil, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", errNotFound
+	}
+	return name, nil
+}
+
+func (o OpenLibrary) authorName(ctx context.Context, key string) (string, error) {
+	var author struct {
+		Name string `json:"name"`
+	}
+	if err := getJSON(ctx, o.Client, o.base()+key+".json", &author); err != nil {
+		return "", err
+	}
+	if author.Name == "" {
+		return "", fmt.Errorf("author name not found")
+	}
+	return author.Name, nil
+}
+
+func (o OpenLibrary) coverURL(id int) string {
+	return fmt.Sprintf("%s/b/id/%d-L.jpg", trimBase(o.CoversURL, "https://covers.openlibrary.org"), id)
+}
+
+// textValue reads Open Library text fields, which are either a string or a
+// {"type": "/type/text", "value": ...} object.
+func textValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var text struct {
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(raw, &text) == nil {
+		return text.Value
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package lookup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rahutchinson/book-list/lookup/lookuptest"
+)
+
+func TestOpenLibraryLookupISBN(t *testing.T) {
+	fake := lookuptest.NewOpenLibrary()
+	defer fake.Close()
+	fake.AddAuthor("/authors/OL26320A", "J.R.R. Tolkien")
+	fake.AddEdition("9780547928227", lookuptest.Edition{
+		Title:         "The Hobbit",
+		Authors:       []lookuptest.Ref{{Key: "/authors/OL26320A"}},
+		NumberOfPages: 300,
+		Subjects:      []string{"Fantasy", "Dragons"},
+		Publishers:    []string{"Mariner Books"},
+		PublishDate:   "September 18, 2012",
+		Covers:        []int{8406786},
+	})
+	fake.AddEdition("9780306406157", lookuptest.Edition{
+		Title:   "Orphaned Author",
+		Authors: []lookuptest.Ref{{Key: "/authors/OL404A"}},
+	})
+
+	tests := []struct {
+		name string
+		isbn string
+		want *Result
+	}{
+		{
+			name: "author resolved by key",
+			isbn: "9780547928227",
+			want: &Result{
+				ISBN: "9780547928227", Title: "The Hobbit", Author: "J.R.R. Tolkien", Pages: 300,
+				Genre: "Fantasy", Publisher: "Mariner Books", Published: time.Date(2012, time.September, 18, 0, 0, 0, 0, time.UTC),
+				Cover: "https://covers.example/b/id/8406786-L.jpg", Sources: []string{"openlibrary"},
+			},
+		},
+		{
+			name: "missing author record leaves the author empty",
+			isbn: "9780306406157",
+			want: &Result{ISBN: "9780306406157", Title: "Orphaned Author", Sources: []string{"openlibrary"}},
+		},
+		{
+			name: "unknown ISBN is not found",
+			isbn: "9780000000002",
+		},
+	}
+
+	provider := OpenLibrary{BaseURL: fake.URL, CoversURL: "https://covers.example", Client: &Client{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.LookupISBN(context.Background(), tt.isbn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupISBN = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var paths []string
+	for _, req := range fake.Requests() {
+		paths = append(paths, req.URL.Path)
+	}
+	want := []string{
+		"/isbn/9780547928227.json", "/authors/OL26320A.json",
+		"/isbn/9780306406157.json", "/authors/OL404A.json",
+		"/isbn/9780000000002.json",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requests = %v, want %v", paths, want)
+	}
+}
+
+func TestOpenLibraryAuthorNotFound(t *testing.T) {
+	fake := lookuptest.NewOpenLibrary()
+	defer fake.Close()
+
+	provider := OpenLibrary{BaseURL: fake.URL, Client: &Client{}, Cache: NewCache(t.TempDir())}
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.AuthorName(context.Background(), "/authors/OL404A"); !IsNotFound(err) {
+			t.Fatalf("AuthorName error = %v, want not found", err)
+		}
+	}
+	if n := len(fake.Requests()); n != 1 {
+		t.Errorf("fake got %d requests, want the second answer from the cache", n)
+	}
+}
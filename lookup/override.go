@@ -0,0 +1,172 @@
+package lookup
+
+import (
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Override corrects lookup results for books a catalogue gets wrong. A rule
+// matches by ISBN (in either form) or by a case-insensitive substring of the
+// title; when both are set, both must match.
+type Override struct {
+	ID            string         `json:"id"`
+	ISBN          string         `json:"isbn,omitempty"`
+	TitleContains string         `json:"title_contains,omitempty"`
+	Set           OverrideFields `json:"set"`
+	// FillOnly applies the rule only to fields the lookup left empty.
+	FillOnly bool   `json:"fill_only,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// Overrides is the on-disk list of rules, applied in order.
+type Overrides struct {
+	Overrides []Override `json:"overrides"`
+}
+
+// OverrideFields are the values a rule sets, one for each field of a
+// Result. Empty fields are left alone.
+type OverrideFields struct {
+	ISBN        string `json:"isbn,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Cover       string `json:"cover,omitempty"`
+	Pages       int    `json:"pages,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Published   string `json:"published,omitempty"` // "2006-01-02", "2006-01" or "2006"
+	Description string `json:"description,omitempty"`
+}
+
+// PublishedDate parses Published, returning the zero time when it is empty
+// or not a date.
+func (f OverrideFields) PublishedDate() time.Time {
+	return parse.Date(f.Published, "2006-01-02", "2006-01", "2006")
+}
+
+// Matches reports whether the rule applies to a lookup of code that found
+// title.
+func (o Override) Matches(code, title string) bool {
+	if o.ISBN == "" && o.TitleContains == "" {
+		return false
+	}
+	if o.ISBN != "" && !isbn.Equal(o.ISBN, code) && isbn.Clean(o.ISBN) != isbn.Clean(code) {
+		return false
+	}
+	if o.TitleContains != "" && !strings.Contains(strings.ToLower(title), strings.ToLower(o.TitleContains)) {
+		return false
+	}
+	return true
+}
+
+// ApplyOverrides applies every matching rule, in order, to the result of
+// looking up code. A rule matching by ISBN alone also applies when the
+// lookup found nothing, so books missing from every catalogue can be filled
+// in by hand. The result is nil when nothing was found and no rule applied.
+func ApplyOverrides(result *Result, code string, rules []Override) *Result {
+	var applied *Result
+	if result != nil {
+		copied := *result
+		applied = &copied
+	}
+
+	for _, rule := range rules {
+		if applied == nil {
+			if rule.ISBN == "" || rule.TitleContains != "" || !rule.Matches(code, "") {
+				continue
+			}
+			applied = &Result{ISBN: isbn.Normalize(code)}
+		}
+		if !rule.Matches(code, applied.Title) {
+			continue
+		}
+
+		rule.apply(applied)
+		applied.Sources = appendSource(applied.Sources, "override")
+	}
+	return applied
+}
+
+// ApplySearchOverrides applies the rules to title search candidates, as
+// ApplyOverrides does to ISBN lookups. A rule with an ISBN matches a
+// candidate listing that ISBN among its editions, and a published date sets
+// the candidate's year.
+func ApplySearchOverrides(candidates []Candidate, rules []Override) []Candidate {
+	applied := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		result := Result{Title: c.Title, Author: c.Author, Cover: c.Cover}
+		if c.Year > 0 {
+			result.Published = time.Date(c.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+		for _, rule := range rules {
+			if rule.matchesCandidate(c.ISBNs, result.Title) {
+				rule.apply(&result)
+			}
+		}
+
+		c.Title, c.Author, c.Cover = result.Title, result.Author, result.Cover
+		if !result.Published.IsZero() {
+			c.Year = result.Published.Year()
+		}
+		applied[i] = c
+	}
+	return applied
+}
+
+func (o Override) matchesCandidate(codes []string, title string) bool {
+	if o.ISBN == "" {
+		return o.Matches("", title)
+	}
+	for _, code := range codes {
+		if o.Matches(code, title) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the rule's fields on r. Both ISBN lookups and title searches
+// go through it, so a rule corrects a book the same way whichever way it
+// was found.
+func (o Override) apply(r *Result) {
+	set := func(dst *string, src string) {
+		if src != "" && (!o.FillOnly || *dst == "") {
+			*dst = src
+		}
+	}
+	set(&r.ISBN, o.Set.ISBN)
+	set(&r.Title, o.Set.Title)
+	set(&r.Author, o.Set.Author)
+	set(&r.Cover, o.Set.Cover)
+	set(&r.Genre, o.Set.Genre)
+	set(&r.Publisher, o.Set.Publisher)
+	set(&r.Description, o.Set.Description)
+	if o.Set.Pages != 0 && (!o.FillOnly || r.Pages == 0) {
+		r.Pages = o.Set.Pages
+	}
+	if published := o.Set.PublishedDate(); !published.IsZero() && (!o.FillOnly || r.Published.IsZero()) {
+		r.Published = published
+	}
+}
+
+// DefaultOverrides are the corrections the shelf starts with: authors Open
+// Library is known to leave out.
+var DefaultOverrides = []Override{
+	{ID: "pride-and-prejudice-isbn", ISBN: "9780141439518", Set: OverrideFields{Author: "Jane Austen"}, FillOnly: true},
+	{ID: "hobbit-isbn", ISBN: "9780547928227", Set: OverrideFields{Author: "J.R.R. Tolkien"}, FillOnly: true},
+	{ID: "mockingbird-isbn", ISBN: "9780061120084", Set: OverrideFields{Author: "Harper Lee"}, FillOnly: true},
+	{ID: "pride-and-prejudice-title", TitleContains: "pride and prejudice", Set: OverrideFields{Author: "Jane Austen"}, FillOnly: true},
+	{ID: "hobbit-title", TitleContains: "hobbit", Set: OverrideFields{Author: "J.R.R. Tolkien"}, FillOnly: true},
+	{ID: "mockingbird-title", TitleContains: "to kill a mockingbird", Set: OverrideFields{Author: "Harper Lee"}, FillOnly: true},
+}
+
+func appendSource(sources []string, source string) []string {
+	for _, s := range sources {
+		if s == source {
+			return sources
+		}
+	}
+	return append(sources, source)
+}
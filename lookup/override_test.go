@@ -0,0 +1,91 @@
+package lookup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	hobbit := &Result{ISBN: "9780547928227", Title: "The Hobbit", Pages: 300, Sources: []string{"openlibrary"}}
+	published := time.Date(1937, time.September, 21, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		result *Result
+		code   string
+		rules  []Override
+		want   *Result
+	}{
+		{
+			name:   "by ISBN in the other form",
+			result: hobbit,
+			code:   "9780547928227",
+			rules:  []Override{{ISBN: "054792822X", Set: OverrideFields{Author: "J.R.R. Tolkien", Pages: 310, Published: "1937-09-21"}}},
+			want:   &Result{ISBN: "9780547928227", Title: "The Hobbit", Author: "J.R.R. Tolkien", Pages: 310, Published: published, Sources: []string{"openlibrary", "override"}},
+		},
+		{
+			name:   "fill only",
+			result: hobbit,
+			code:   "9780547928227",
+			rules:  []Override{{TitleContains: "HOBBIT", Set: OverrideFields{Title: "Other", Pages: 1, Genre: "Fantasy"}, FillOnly: true}},
+			want:   &Result{ISBN: "9780547928227", Title: "The Hobbit", Pages: 300, Genre: "Fantasy", Sources: []string{"openlibrary", "override"}},
+		},
+		{
+			name:   "title and ISBN must both match",
+			result: hobbit,
+			code:   "9780547928227",
+			rules:  []Override{{ISBN: "9780547928227", TitleContains: "dune", Set: OverrideFields{Genre: "Fantasy"}}},
+			want:   hobbit,
+		},
+		{
+			name:  "answers unknown ISBNs",
+			code:  "978-0-306-40615-7",
+			rules: []Override{{ISBN: "9780306406157", Set: OverrideFields{Title: "Hand Entered", Publisher: "Self"}}},
+			want:  &Result{ISBN: "9780306406157", Title: "Hand Entered", Publisher: "Self", Sources: []string{"override"}},
+		},
+		{
+			name:  "title rules do not answer unknown ISBNs",
+			code:  "9780306406157",
+			rules: []Override{{TitleContains: "hand", Set: OverrideFields{Title: "Hand Entered"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyOverrides(tt.result, tt.code, tt.rules)
+			if (got == nil) != (tt.want == nil) || got != nil && !reflect.DeepEqual(*got, *tt.want) {
+				t.Errorf("ApplyOverrides = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if hobbit.Author != "" || len(hobbit.Sources) != 1 {
+		t.Errorf("ApplyOverrides changed its input: %+v", hobbit)
+	}
+}
+
+func TestApplySearchOverrides(t *testing.T) {
+	candidates := []Candidate{
+		{Key: "/works/OL2W", Title: "The Hobbit", Year: 1950, ISBNs: []string{"9780547928227"}},
+		{Key: "/works/OL3W", Title: "Dune", Author: "Frank Herbert"},
+	}
+	rules := []Override{
+		{ISBN: "054792822X", Set: OverrideFields{Author: "J.R.R. Tolkien", Published: "1937"}},
+		{TitleContains: "dune", Set: OverrideFields{Author: "Someone Else", Cover: "https://example.com/dune.jpg"}, FillOnly: true},
+	}
+
+	got := ApplySearchOverrides(candidates, rules)
+	want := []Candidate{
+		{Key: "/works/OL2W", Title: "The Hobbit", Author: "J.R.R. Tolkien", Year: 1937, ISBNs: []string{"9780547928227"}},
+		{Key: "/works/OL3W", Title: "Dune", Author: "Frank Herbert", Cover: "https://example.com/dune.jpg"},
+	}
+	for i := range want {
+		if got[i].Title != want[i].Title || got[i].Author != want[i].Author || got[i].Year != want[i].Year || got[i].Cover != want[i].Cover {
+			t.Errorf("candidate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if candidates[0].Author != "" {
+		t.Errorf("ApplySearchOverrides changed its input: %+v", candidates[0])
+	}
+}
@@ -0,0 +1,173 @@
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+)
+
+// Candidate is a work found by a title and author search, for the user to
+// pick from when a book has no ISBN to look up.
+type Candidate struct {
+	Key          string   `json:"key"` // Open Library work key, e.g. "/works/OL27448W"
+	Title        string   `json:"title"`
+	Author       string   `json:"author,omitempty"`
+	Year         int      `json:"year,omitempty"` // first published
+	Cover        string   `json:"cover,omitempty"`
+	EditionCount int      `json:"edition_count"`
+	ISBNs        []string `json:"isbns,omitempty"` // canonical ISBN-13s of its editions
+	Score        float64  `json:"score"`
+}
+
+// maxCandidateISBNs caps the ISBNs listed per candidate; popular works have
+// hundreds of editions.
+const maxCandidateISBNs = 20
+
+type openLibrarySearch struct {
+	Docs []struct {
+		Key              string   `json:"key"`
+		Title            string   `json:"title"`
+		AuthorName       []string `json:"author_name"`
+		FirstPublishYear int      `json:"first_publish_year"`
+		CoverI           int      `json:"cover_i"`
+		EditionCount     int      `json:"edition_count"`
+		ISBN             []string `json:"isbn"`
+	} `json:"docs"`
+}
+
+// Search finds works by title and author with Open Library's search API and
+// returns at most limit candidates, best match first. Either title or author
+// may be empty.
+func (o OpenLibrary) Search(ctx context.Context, title, author string, limit int) ([]Candidate, error) {
+	if strings.TrimSpace(title) == "" && strings.TrimSpace(author) == "" {
+		return nil, fmt.Errorf("a title or author is required")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := url.Values{
+		"fields": {"key,title,author_name,first_publish_year,cover_i,edition_count,isbn"},
+		// Ask for more than needed so re-ranking has something to work with.
+		"limit": {fmt.Sprint(limit * 2)},
+	}
+	if title != "" {
+		query.Set("title", title)
+	}
+	if author != "" {
+		query.Set("author", author)
+	}
+
+	var response openLibrarySearch
+	err := getJSON(ctx, o.Client, o.base()+"/search.json?"+query.Encode(), &response)
+	if IsNotFound(err) {
+		return []Candidate{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, 0, len(response.Docs))
+	for i, doc := range response.Docs {
+		c := Candidate{
+			Key:          doc.Key,
+			Title:        doc.Title,
+			Year:         doc.FirstPublishYear,
+			EditionCount: doc.EditionCount,
+		}
+		if len(doc.AuthorName) > 0 {
+			c.Author = doc.AuthorName[0]
+		}
+		if doc.CoverI > 0 {
+			c.Cover = o.coverURL(doc.CoverI)
+		}
+		c.ISBNs = canonicalISBNs(doc.ISBN, maxCandidateISBNs)
+
+		// Open Library's own order breaks ties.
+		c.Score = matchScore(c, title, author) - float64(i)*0.001
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// matchScore ranks a candidate by how well its title and author match the
+// query, with popular works (many editions) and works with covers and ISBNs
+// preferred among equal matches.
+func matchScore(c Candidate, title, author string) float64 {
+	score := 0.0
+	if title != "" {
+		score += 3 * similarity(c.Title, title)
+	}
+	if author != "" {
+		score += 2 * similarity(c.Author, author)
+	}
+	score += math.Log10(float64(c.EditionCount)+1) / 2
+	if c.Cover != "" {
+		score += 0.2
+	}
+	if len(c.ISBNs) > 0 {
+		score += 0.2
+	}
+	return score
+}
+
+// similarity is 1 for equal strings after normalization, and otherwise the
+// share of query words found in s.
+func similarity(s, query string) float64 {
+	a, b := words(s), words(query)
+	if len(b) == 0 {
+		return 0
+	}
+	if strings.Join(a, " ") == strings.Join(b, " ") {
+		return 1
+	}
+
+	have := make(map[string]bool, len(a))
+	for _, w := range a {
+		have[w] = true
+	}
+	found := 0
+	for _, w := range b {
+		if have[w] {
+			found++
+		}
+	}
+	// Extra words in s (subtitles, series names) cost a little.
+	return float64(found) / float64(len(b)) * (0.9 - 0.1*math.Min(1, float64(len(a)-found)/5))
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// canonicalISBNs converts ISBNs to ISBN-13, dropping invalid ones and
+// duplicates, and keeps at most max of them.
+func canonicalISBNs(codes []string, max int) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		canonical, err := isbn.Canonical(code)
+		if err != nil || seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		out = append(out, canonical)
+		if len(out) == max {
+			break
+		}
+	}
+	return out
+}
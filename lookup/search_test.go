@@ -0,0 +1,62 @@
+package lookup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rahutchinson/book-list/lookup/lookuptest"
+)
+
+func TestSearch(t *testing.T) {
+	fake := lookuptest.NewOpenLibrary()
+	defer fake.Close()
+	fake.AddWork(lookuptest.Work{Key: "/works/OL1W", Title: "The Hobbit Companion", AuthorName: []string{"David Day"}, EditionCount: 5})
+	fake.AddWork(lookuptest.Work{Key: "/works/OL2W", Title: "The Hobbit", AuthorName: []string{"J.R.R. Tolkien"}, FirstPublishYear: 1937, CoverI: 42, EditionCount: 300, ISBN: []string{"054792822X", "9780547928227", "not an isbn"}})
+	fake.AddWork(lookuptest.Work{Key: "/works/OL3W", Title: "Dune", AuthorName: []string{"Frank Herbert"}})
+
+	tests := []struct {
+		name          string
+		title, author string
+		limit         int
+		wantKeys      []string
+	}{
+		{name: "best match first", title: "hobbit", wantKeys: []string{"/works/OL2W", "/works/OL1W"}},
+		{name: "by author", title: "hobbit", author: "day", wantKeys: []string{"/works/OL1W"}},
+		{name: "limit", title: "hobbit", limit: 1, wantKeys: []string{"/works/OL2W"}},
+		{name: "no match", title: "silmarillion", wantKeys: []string{}},
+	}
+
+	provider := OpenLibrary{BaseURL: fake.URL, CoversURL: "https://covers.example", Client: &Client{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := provider.Search(context.Background(), tt.title, tt.author, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := []string{}
+			for _, c := range candidates {
+				keys = append(keys, c.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("Search(%q, %q) = %v, want %v", tt.title, tt.author, keys, tt.wantKeys)
+			}
+		})
+	}
+
+	candidates, err := provider.Search(context.Background(), "The Hobbit", "Tolkien", 10)
+	if err != nil || len(candidates) == 0 {
+		t.Fatalf("Search = %v, %v", candidates, err)
+	}
+	hobbit := candidates[0]
+	if want := []string{"9780547928227"}; !reflect.DeepEqual(hobbit.ISBNs, want) {
+		t.Errorf("ISBNs = %v, want %v", hobbit.ISBNs, want)
+	}
+	if hobbit.Author != "J.R.R. Tolkien" || hobbit.Year != 1937 || hobbit.Cover == "" {
+		t.Errorf("candidate = %+v, want author, year and cover filled in", hobbit)
+	}
+
+	if _, err := provider.Search(context.Background(), " ", "", 10); err == nil {
+		t.Error("Search without a title or author succeeded")
+	}
+}
@@ -1,47 +1,137 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	htmltemplate "html/template"
 	"io"
 	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
+	"unicode/utf8"
 
+	barcode "github.com/rahutchinson/book-list/barcode"
+	coverstore "github.com/rahutchinson/book-list/coverstore"
+	enrich "github.com/rahutchinson/book-list/enrich"
+	epub "github.com/rahutchinson/book-list/epub"
+	export "github.com/rahutchinson/book-list/export"
+	feed "github.com/rahutchinson/book-list/feed"
+	importer "github.com/rahutchinson/book-list/importer"
+	isbn "github.com/rahutchinson/book-list/isbn"
+	lookup "github.com/rahutchinson/book-list/lookup"
 	models "github.com/rahutchinson/book-list/models"
+	opds "github.com/rahutchinson/book-list/opds"
+	site "github.com/rahutchinson/book-list/site"
+	vault "github.com/rahutchinson/book-list/vault"
+	web "github.com/rahutchinson/book-list/web"
 )
 
 var (
 	httpAddr = flag.String("http", defaultAddr(), "http listen address")
 	postKey  = os.Getenv("POST_KEY")
-	index    *template.Template
+	index    *htmltemplate.Template
+	bookPage *htmltemplate.Template
+	shelfPage *htmltemplate.Template
+	statsPage *htmltemplate.Template
 	booksFile = "books.json"
 	booksMutex sync.RWMutex
+	coversDir = "covers"
+	coverStore = coverstore.NewStore(coversDir)
+	sharesFile = "shares.json"
+	sharesMutex sync.RWMutex
+	metadata    lookup.MetadataProvider
+	lookupCache *lookup.Cache
+	// searcher finds candidate editions by title and author; only Open
+	// Library offers a search API.
+	searcher lookup.OpenLibrary
+
+	overridesFile  = "overrides.json"
+	overridesMutex sync.RWMutex
+
+	enrichFile = "enrich.json"
+	enricher   *enrich.Job
+
+	goalsFile  = "goals.json"
+	goalsMutex sync.RWMutex
+
+	// calendarToken lets calendar apps read loans and private books. It only
+	// reads, so subscription URLs kept by calendar services never carry the
+	// post key.
+	calendarToken = os.Getenv("CALENDAR_TOKEN")
 )
 
 func main() {
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var err error
+	if metadata, err = newMetadataProvider(); err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize books file if it doesn't exist
 	if _, err := os.Stat(booksFile); os.IsNotExist(err) {
 		initializeBooksFile()
 	}
 
+	if _, err := os.Stat(overridesFile); os.IsNotExist(err) {
+		initializeOverridesFile()
+	}
+
+	if enricher, err = newEnricher(); err != nil {
+		log.Fatal(err)
+	}
+	if err := enricher.Resume(); err != nil {
+		log.Printf("Error resuming enrichment: %v", err)
+	}
+
 	http.HandleFunc("/", indexHandler)
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/book/", bookPageHandler)
+	http.HandleFunc("/shares", sharesHandler)
+	http.HandleFunc("/share/", sharedShelfHandler)
 	http.HandleFunc("/books", bookHandler)
 	http.HandleFunc("/books/filter", filterHandler)
 	http.HandleFunc("/books/stats", statsHandler)
 	http.HandleFunc("/books/lookup", lookupHandler)
+	http.HandleFunc("/books/lookup/cache", lookupCacheHandler)
+	http.HandleFunc("/books/scan", scanHandler)
+	http.HandleFunc("/books/enrich", enrichHandler)
+	http.HandleFunc("/books/enrich/proposals", enrichProposalsHandler)
+	http.HandleFunc("/overrides", overridesHandler)
+	http.HandleFunc("/books/import", importHandler)
+	http.HandleFunc("/books/export", exportHandler)
+	http.HandleFunc("/books/epub", epubHandler)
 	http.HandleFunc("/featured", featuredHandler)
+	http.HandleFunc("/feeds/", feedHandler)
+	http.HandleFunc("/calendar.ics", calendarHandler)
+	http.HandleFunc("/goals", goalsHandler)
+	http.Handle("/opds/", catalogHandler("/opds", false))
+	http.Handle("/opds2/", catalogHandler("/opds2", true))
 	fs := http.FileServer(http.Dir("./js/"))
 	http.Handle("/js/", http.StripPrefix("/js", fs))
+	coverStore.Fallback = coverSource
+	http.Handle("/covers/", http.StripPrefix("/covers", coverStore))
 
 	log.Print("Running at address ", *httpAddr)
 	log.Fatal(http.ListenAndServe(*httpAddr, nil))
@@ -59,7 +149,6 @@ func initializeBooksFile() {
 				Rating:      5,
 				Genre:       "Classic",
 				Pages:       180,
-				Cover:       "https://via.placeholder.com/160x240/f8f9fa/6c757d?text=The+Great+Gatsby",
 				Added:       time.Now(),
 				Description: "A story of the fabulously wealthy Jay Gatsby and his love for the beautiful Daisy Buchanan.",
 			},
@@ -72,7 +161,6 @@ func initializeBooksFile() {
 				Rating:      0,
 				Genre:       "Dystopian",
 				Pages:       328,
-				Cover:       "https://via.placeholder.com/160x240/f8f9fa/6c757d?text=1984",
 				Added:       time.Now(),
 				Started:     time.Now(),
 				Description: "A dystopian novel about totalitarianism and surveillance society.",
@@ -100,6 +188,15 @@ func loadBooks() models.Books {
 		return models.Books{Books: []models.Book{}}
 	}
 	
+	// Books saved before ISBNs were normalized may hold ISBN-10s or
+	// hyphenated codes; they are read as canonical ISBN-13s and written back
+	// that way on the next save. Invalid codes are left as they are.
+	for i := range books.Books {
+		if canonical, err := isbn.Canonical(books.Books[i].ISBN); err == nil {
+			books.Books[i].ISBN = canonical
+		}
+	}
+	
 	return books
 }
 
@@ -134,21 +231,385 @@ func indexHandler(w http.ResponseWriter, req *http.Request) {
 	}
 	params := models.IndexParams{
 		Host: req.Host,
+		Root: "/",
 	}
 	w.Header().Set("Cache-Control", "no-cache")
 	index.Execute(w, params)
 }
 
+// bookPageHandler renders a shareable page for a single book at /book/{id},
+// with schema.org JSON-LD and link-preview tags.
+func bookPageHandler(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/book/"), "/")
+	if id == "" || strings.Contains(id, "/") || bookPage == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	for _, book := range loadBooks().Books {
+		if book.ID == id && !book.Private {
+			// Link previews do not take SVG, so they get the PNG placeholder.
+			page := book
+			page.Cover = displayCover(book, "png")
+			params := newBookPageParams(page, models.IndexParams{Host: req.Host, Root: "/"}, web.BaseURL(req))
+			params.Book.Cover = displayCover(book, "svg")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := bookPage.Execute(w, params); err != nil {
+				log.Printf("Error rendering book page: %v", err)
+			}
+			return
+		}
+	}
+
+	http.NotFound(w, req)
+}
+
+// newBookPageParams builds the template data for a book page. base is the
+// scheme and host the page is served from.
+func newBookPageParams(book models.Book, index models.IndexParams, base string) models.BookPageParams {
+	params := models.BookPageParams{
+		IndexParams: index,
+		Book:        book,
+		URL:         base + "/book/" + url.PathEscape(book.ID),
+		Image:       web.AbsoluteURL(base, book.Cover),
+		Summary:     summarize(book.Description, 200),
+		Stars:       make([]int, int(math.Round(book.Rating))),
+	}
+	if params.Summary == "" && book.Author != "" {
+		params.Summary = book.Name + " by " + book.Author
+	}
+	params.JSONLD = bookJSONLD(book, params.URL, params.Image)
+	return params
+}
+
+// bookJSONLD describes a book as a schema.org Book, with the shelf's
+// rating as a Review.
+func bookJSONLD(book models.Book, pageURL, image string) map[string]interface{} {
+	ld := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "Book",
+		"@id":      pageURL,
+		"url":      pageURL,
+		"name":     book.Name,
+	}
+	if book.Author != "" {
+		ld["author"] = map[string]interface{}{"@type": "Person", "name": book.Author}
+	}
+	if book.ISBN != "" {
+		ld["isbn"] = book.ISBN
+	}
+	if image != "" {
+		ld["image"] = image
+	}
+	if book.Description != "" {
+		ld["description"] = book.Description
+	}
+	if book.Genre != "" {
+		ld["genre"] = book.Genre
+	}
+	if len(book.Tags) > 0 {
+		ld["keywords"] = strings.Join(book.Tags, ", ")
+	}
+	if book.Publisher != "" {
+		ld["publisher"] = map[string]interface{}{"@type": "Organization", "name": book.Publisher}
+	}
+	if !book.Published.IsZero() {
+		ld["datePublished"] = book.Published.Format("2006-01-02")
+	}
+	if book.Pages > 0 {
+		ld["numberOfPages"] = book.Pages
+	}
+	if book.Series != "" {
+		series := map[string]interface{}{"@type": "BookSeries", "name": book.Series}
+		ld["isPartOf"] = series
+		if book.SeriesOrder > 0 {
+			ld["position"] = book.SeriesOrder
+		}
+	}
+
+	var formats []string
+	for _, t := range book.Type {
+		switch t {
+		case models.Physical:
+			formats = append(formats, "https://schema.org/Hardcover")
+		case models.Kindle, models.Ebook:
+			formats = append(formats, "https://schema.org/EBook")
+		case models.Audible:
+			formats = append(formats, "https://schema.org/AudiobookFormat")
+		}
+	}
+	if len(formats) > 0 {
+		ld["bookFormat"] = formats[0]
+	}
+
+	if book.Rating > 0 {
+		ld["review"] = map[string]interface{}{
+			"@type": "Review",
+			"reviewRating": map[string]interface{}{
+				"@type":       "Rating",
+				"ratingValue": book.Rating,
+				"bestRating":  5,
+				"worstRating": 1,
+			},
+			"author": map[string]interface{}{"@type": "Organization", "name": catalogTitle},
+		}
+	}
+
+	return ld
+}
+
+// summarize shortens text to at most n bytes at a word boundary, or inside
+// a single long word without splitting a UTF-8 character.
+func summarize(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= n {
+		return text
+	}
+	cut := strings.LastIndex(text[:n], " ")
+	if cut <= 0 {
+		cut = n
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+	}
+	return text[:cut] + "…"
+}
+
+func loadShares() models.Shares {
+	sharesMutex.RLock()
+	defer sharesMutex.RUnlock()
+
+	data, err := os.ReadFile(sharesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading shares file: %v", err)
+		}
+		return models.Shares{Shares: []models.Share{}}
+	}
+
+	var shares models.Shares
+	if err := json.Unmarshal(data, &shares); err != nil {
+		log.Printf("Error parsing shares file: %v", err)
+		return models.Shares{Shares: []models.Share{}}
+	}
+
+	return shares
+}
+
+func saveShares(shares models.Shares) error {
+	sharesMutex.Lock()
+	defer sharesMutex.Unlock()
+
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Tokens grant access, so keep the file private to the server user.
+	return os.WriteFile(sharesFile, data, 0600)
+}
+
+// newShareToken returns an unguessable URL-safe token.
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sharesHandler lists (GET ?key=), creates (POST) and revokes (DELETE)
+// shared shelves. All three require the post key.
+func sharesHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("key") != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loadShares())
+
+	case http.MethodPost:
+		var s models.PostShare
+		if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+			http.Error(w, "Bad POST", 400)
+			return
+		}
+
+		if s.Key != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+
+		token, err := newShareToken()
+		if err != nil {
+			http.Error(w, "Failed to create share", 500)
+			return
+		}
+		s.Share.Token = token
+		s.Share.Created = time.Now()
+
+		shares := loadShares()
+		shares.Shares = append(shares.Shares, s.Share)
+		if err := saveShares(shares); err != nil {
+			http.Error(w, "Failed to save share", 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"share": s.Share,
+			"html":  web.BaseURL(req) + "/share/" + token,
+			"json":  web.BaseURL(req) + "/share/" + token + ".json",
+		})
+
+	case http.MethodDelete:
+		var s models.PostShare
+		if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+			http.Error(w, "Bad Delete", 400)
+			return
+		}
+
+		if s.Key != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+
+		shares := loadShares()
+		found := false
+		for i, share := range shares.Shares {
+			if share.Token == s.Share.Token {
+				shares.Shares = append(shares.Shares[:i], shares.Shares[i+1:]...)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			http.Error(w, "Share not found", 404)
+			return
+		}
+
+		if err := saveShares(shares); err != nil {
+			http.Error(w, "Failed to revoke share", 500)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sharedShelfHandler serves a shared shelf read-only, as HTML at
+// /share/{token} or JSON at /share/{token}.json.
+func sharedShelfHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(req.URL.Path, "/share/")
+	asJSON := strings.HasSuffix(token, ".json")
+	token = strings.TrimSuffix(token, ".json")
+
+	share := findShare(token)
+	if share == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	books := sharedBooks(loadBooks().Books, *share)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":  share.Name,
+			"books": books,
+		})
+		return
+	}
+
+	if shelfPage == nil {
+		http.Error(w, "Shelf template missing", 500)
+		return
+	}
+	for i := range books {
+		books[i].Cover = displayCover(books[i], "svg")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	shelfPage.Execute(w, models.SharedShelfParams{
+		IndexParams: models.IndexParams{Host: req.Host, Root: "/"},
+		Share:       *share,
+		Books:       books,
+	})
+}
+
+// findShare returns the share with the given token, or nil.
+func findShare(token string) *models.Share {
+	if token == "" {
+		return nil
+	}
+	shares := loadShares()
+	for i := range shares.Shares {
+		if subtle.ConstantTimeCompare([]byte(shares.Shares[i].Token), []byte(token)) == 1 {
+			return &shares.Shares[i]
+		}
+	}
+	return nil
+}
+
+// publicBooks is the library as anyone without the post key sees it:
+// without private books, and without the notes of books that hide them.
+func publicBooks() []models.Book {
+	return sharedBooks(loadBooks().Books, models.Share{ShowNotes: true})
+}
+
+// catalogHandler returns the OPDS catalog served under prefix, as OPDS 2
+// JSON when asJSON is set. Catalog readers carry no key, so it only lists
+// publicBooks.
+func catalogHandler(prefix string, asJSON bool) opds.Handler {
+	return opds.Handler{Prefix: prefix, Title: catalogTitle, JSON: asJSON, Books: publicBooks, Search: searchBooks}
+}
+
+// visibleBooks returns the whole library for requests carrying the post key
+// (?key=), or when none is configured, and publicBooks otherwise.
+func visibleBooks(req *http.Request) []models.Book {
+	if req.URL.Query().Get("key") == postKey || postKey == "" {
+		return loadBooks().Books
+	}
+	return publicBooks()
+}
+
+// sharedBooks applies a share's filter and the per-book privacy flags.
+func sharedBooks(books []models.Book, share models.Share) []models.Book {
+	shared := []models.Book{}
+	for _, book := range filterBooks(books, share.Filter) {
+		if book.Private {
+			continue
+		}
+		if !share.ShowNotes || book.HideNotes {
+			book.Notes = ""
+		}
+		book.Loan = nil
+		shared = append(shared, book)
+	}
+	return shared
+}
+
 func featuredHandler(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		w.Header().Set("Content-Type", "application/json")
-		books := loadBooks()
 		var featured []string
-		for _, book := range books.Books {
-			if book.Status == models.Reading {
-				featured = append(featured, book.ID)
-			}
+		for _, book := range currentlyReading(visibleBooks(req)) {
+			featured = append(featured, book.ID)
 		}
 		json.NewEncoder(w).Encode(featured)
 	default:
@@ -156,12 +617,103 @@ func featuredHandler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// currentlyReading returns the books shown as featured.
+func currentlyReading(books []models.Book) []models.Book {
+	var reading []models.Book
+	for _, book := range books {
+		if book.Status == models.Reading {
+			reading = append(reading, book)
+		}
+	}
+	return reading
+}
+
+// feedHandler serves Atom and RSS 2.0 feeds at /feeds/{name}.atom and
+// /feeds/{name}.rss, where name is finished, added or reading. With the
+// token of a shared shelf (?share=) it serves that shelf instead, with notes
+// when the share shows them.
+func feedHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(req.URL.Path, "/feeds/")
+	ext := filepath.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+	if ext != ".atom" && ext != ".rss" {
+		http.NotFound(w, req)
+		return
+	}
+
+	base := web.BaseURL(req)
+	books := publicBooks()
+	withNotes := false
+
+	// Notes are personal, so they need a share, which can be revoked without
+	// giving out the post key. The token never appears in the feed, whose
+	// links are the plain public ones, and the response is not cached.
+	token := req.URL.Query().Get("share")
+	if token != "" {
+		share := findShare(token)
+		if share == nil {
+			http.NotFound(w, req)
+			return
+		}
+		books = sharedBooks(loadBooks().Books, *share)
+		withNotes = share.ShowNotes
+	}
+	self := base + req.URL.Path
+
+	f := feed.Feed{
+		Link:    base + "/",
+		Self:    self,
+		Updated: time.Now(),
+	}
+	switch name {
+	case "finished":
+		f.Title = catalogTitle + ": Recently Finished"
+		f.Description = "Books most recently finished"
+		for _, book := range feed.RecentlyFinished(books, 50) {
+			f.Items = append(f.Items, feed.BookItem(book, base, book.Finished, withNotes))
+		}
+	case "added":
+		f.Title = catalogTitle + ": Recently Added"
+		f.Description = "Books most recently added to the shelf"
+		for _, book := range feed.RecentlyAdded(books, 50) {
+			f.Items = append(f.Items, feed.BookItem(book, base, book.Added, withNotes))
+		}
+	case "reading":
+		f.Title = catalogTitle + ": Currently Reading"
+		f.Description = "Books being read right now"
+		for _, book := range currentlyReading(books) {
+			f.Items = append(f.Items, feed.BookItem(book, base, book.Started, withNotes))
+		}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	if token != "" {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Set("X-Robots-Tag", "noindex")
+	} else {
+		w.Header().Set("Cache-Control", "max-age=300")
+	}
+	if ext == ".atom" {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		feed.WriteAtom(w, f)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	feed.WriteRSS(w, f)
+}
+
 func bookHandler(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		w.Header().Set("Content-Type", "application/json")
-		books := loadBooks()
-		json.NewEncoder(w).Encode(books)
+		json.NewEncoder(w).Encode(models.Books{Books: visibleBooks(req)})
 		
 	case http.MethodPost:
 		var b models.PostBook
@@ -171,6 +723,12 @@ func bookHandler(w http.ResponseWriter, req *http.Request) {
 		}
 		
 		if b.Key == postKey || postKey == "" {
+			if err := normalizeBookISBN(&b.Book, ""); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			localizeCover(req.Context(), &b.Book, nil)
+
 			books := loadBooks()
 			b.Book.ID = generateID()
 			b.Book.Added = time.Now()
@@ -199,6 +757,11 @@ func bookHandler(w http.ResponseWriter, req *http.Request) {
 			found := false
 			for i, book := range books.Books {
 				if book.ID == b.Book.ID {
+					if err := normalizeBookISBN(&b.Book, book.ISBN); err != nil {
+						http.Error(w, err.Error(), 400)
+						return
+					}
+					localizeCover(req.Context(), &b.Book, &book)
 					books.Books[i] = b.Book
 					found = true
 					break
@@ -258,6 +821,25 @@ func bookHandler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// normalizeBookISBN stores a book's ISBN as a canonical ISBN-13. An invalid
+// ISBN is rejected unless it is the one the book already had, so books
+// imported with other identifiers stay editable.
+func normalizeBookISBN(book *models.Book, previous string) error {
+	if strings.TrimSpace(book.ISBN) == "" {
+		book.ISBN = ""
+		return nil
+	}
+	canonical, err := isbn.Canonical(book.ISBN)
+	if err != nil {
+		if previous != "" && book.ISBN == previous {
+			return nil
+		}
+		return err
+	}
+	book.ISBN = canonical
+	return nil
+}
+
 func filterHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -270,13 +852,23 @@ func filterHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	allBooks := loadBooks()
-	filteredBooks := filterBooks(allBooks.Books, filter)
+	filteredBooks := filterBooks(visibleBooks(req), filter)
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(models.Books{Books: filteredBooks})
 }
 
+const catalogTitle = "Virtual Bookshelf"
+
+func allBooks() []models.Book {
+	return loadBooks().Books
+}
+
+// searchBooks runs the free-text part of filterBooks on its own.
+func searchBooks(books []models.Book, query string) []models.Book {
+	return filterBooks(books, models.BookFilter{Search: query})
+}
+
 func filterBooks(books []models.Book, filter models.BookFilter) []models.Book {
 	var filtered []models.Book
 	
@@ -343,7 +935,7 @@ func filterBooks(books []models.Book, filter models.BookFilter) []models.Book {
 		}
 		
 		// Rating filter
-		if filter.Rating > 0 && book.Rating < filter.Rating {
+		if filter.Rating > 0 && book.Rating < float64(filter.Rating) {
 			continue
 		}
 		
@@ -373,8 +965,7 @@ func statsHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	books := loadBooks()
-	stats := calculateStats(books.Books)
+	stats := calculateStats(visibleBooks(req))
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
@@ -388,8 +979,9 @@ func calculateStats(books []models.Book) models.BookStats {
 		ByGenre:    make(map[string]int),
 	}
 	
-	var totalRating int
+	var totalRating float64
 	var completedPages int
+	var listenedHours float64
 	
 	for _, book := range books {
 		// Count by type
@@ -414,61 +1006,927 @@ func calculateStats(books []models.Book) models.BookStats {
 		if book.Status == models.Completed && book.Pages > 0 {
 			completedPages += book.Pages
 		}
+		
+		// Calculate hours listened
+		if book.Status == models.Completed && book.Duration > 0 {
+			for _, bookType := range book.Type {
+				if bookType == models.Audible {
+					listenedHours += book.Duration.Hours()
+					break
+				}
+			}
+		}
 	}
 	
 	// Calculate average rating
 	if len(books) > 0 {
-		stats.AverageRating = float64(totalRating) / float64(len(books))
+		stats.AverageRating = totalRating / float64(len(books))
 	}
 	
 	stats.PagesRead = completedPages
+	stats.HoursListened = int(math.Round(listenedHours))
 	
 	return stats
 }
 
-func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
-}
-
-func defaultAddr() string {
-	port := os.Getenv("PORT")
-	if port != "" {
-		return ":" + port
+// runCommand dispatches the command-line subcommands.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "import":
+		return importCommand(args[1:])
+	case "export":
+		return exportCommand(args[1:])
+	case "build":
+		return buildCommand(args[1:])
+	case "vault":
+		return vaultCommand(args[1:])
+	case "covers":
+		return coversCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
 	}
-
-	return ":4000"
 }
 
-func lookupHandler(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "goodreads", "export format: goodreads, storygraph, librarything, audible or calibre")
+	dryRun := fs.Bool("dry-run", false, "show the changes without saving")
+	mappingFile := fs.String("mapping", "", "JSON file overriding the column mapping")
+	fs.Parse(args)
 
-	var request struct {
-		ISBN string `json:"isbn"`
-	}
-	
-	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
-		http.Error(w, "Bad request", 400)
-		return
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-format name] [-dry-run] [-mapping file] <export-file or calibre-library>")
 	}
 
-	if request.ISBN == "" {
-		http.Error(w, "ISBN is required", 400)
+	var incoming []models.Book
+	switch *format {
+	case "calibre":
+		books, err := importer.Calibre(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if !*dryRun {
+			storeCalibreCovers(fs.Arg(0), books)
+		}
+		incoming = books
+
+	default:
+		var mapping importer.Mapping
+		if *mappingFile != "" {
+			data, err := os.ReadFile(*mappingFile)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &mapping); err != nil {
+				return fmt.Errorf("parsing mapping: %w", err)
+			}
+		}
+
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		books, err := parseImport(*format, f, mapping)
+		if err != nil {
+			return err
+		}
+		incoming = books
+	}
+
+	result, err := mergeImport(incoming, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range result.Changes {
+		switch change.Action {
+		case importer.Add:
+			fmt.Printf("+ %s by %s\n", change.Book.Name, change.Book.Author)
+		case importer.Update:
+			fmt.Printf("~ %s by %s (%s)\n", change.Book.Name, change.Book.Author, strings.Join(change.Fields, ", "))
+		}
+	}
+	fmt.Printf("%d added, %d updated, %d unchanged\n", result.Added, result.Updated, result.Skipped)
+	if *dryRun {
+		fmt.Println("Dry run: no changes saved")
+	}
+	return nil
+}
+
+// vaultCommand writes the library as Markdown notes for an Obsidian vault.
+// With -incremental only changed notes are rewritten, and text outside the
+// generated sections is kept.
+func vaultCommand(args []string) error {
+	fs := flag.NewFlagSet("vault", flag.ExitOnError)
+	output := fs.String("o", "obsidian", "vault directory")
+	incremental := fs.Bool("incremental", false, "only rewrite changed notes, keeping everything outside the generated sections")
+	filterJSON := fs.String("filter", "", "JSON BookFilter to apply")
+	fs.Parse(args)
+
+	books := loadBooks().Books
+	if *filterJSON != "" {
+		var filter models.BookFilter
+		if err := json.Unmarshal([]byte(*filterJSON), &filter); err != nil {
+			return fmt.Errorf("parsing filter: %w", err)
+		}
+		books = filterBooks(books, filter)
+	}
+
+	report, err := vault.Vault{Dir: *output, Incremental: *incremental}.Write(books)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range report.Skipped {
+		fmt.Printf("! %s has no generated section, left alone\n", name)
+	}
+	fmt.Printf("%d written, %d unchanged, %d skipped\n", len(report.Written), len(report.Unchanged), len(report.Skipped))
+	return nil
+}
+
+// buildCommand renders the library into a static site.
+func buildCommand(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	output := fs.String("o", "public", "output directory")
+	base := fs.String("base", "http://localhost:4000", "public URL the site will be hosted at")
+	notes := fs.Bool("notes", false, "include notes of books not marked hide_notes")
+	fs.Parse(args)
+
+	baseURL := strings.TrimSuffix(*base, "/")
+	books := sharedBooks(loadBooks().Books, models.Share{ShowNotes: *notes})
+
+	s := site.Site{
+		Dir:       *output,
+		BaseURL:   baseURL,
+		Title:     catalogTitle,
+		IndexPage: index,
+		BookPage:  bookPage,
+		ShelfPage: shelfPage,
+		StatsPage: statsPage,
+		BookParams: func(book models.Book) models.BookPageParams {
+			host := strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://")
+			return newBookPageParams(book, models.IndexParams{Host: host}, baseURL)
+		},
+		Stats:  calculateStats,
+		Covers: coverStore,
+		Assets: map[string]string{
+			"js": "js",
+		},
+	}
+	if err := s.Build(books); err != nil {
+		return err
+	}
+
+	fmt.Printf("Built %d books into %s\n", len(books), *output)
+	return nil
+}
+
+// storeCalibreCovers copies the local cover images importer.Calibre found
+// into the cover store and points the books at the stored copies. Only
+// images inside the library directory are copied, whatever metadata.opf
+// says, and books whose cover cannot be stored are left without one.
+func storeCalibreCovers(dir string, books []models.Book) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		root = dir
+	}
+	for i := range books {
+		path := books[i].Cover
+		books[i].Cover = ""
+		if path == "" {
+			continue
+		}
+		cover, err := storeCover(root, path)
+		if err != nil {
+			log.Printf("Error storing cover for %s: %v", books[i].Name, err)
+			continue
+		}
+		books[i].Cover = cover
+	}
+}
+
+// parseImport reads an export in one of the delimited import formats.
+func parseImport(format string, r io.Reader, mapping importer.Mapping) ([]models.Book, error) {
+	f, ok := importer.Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	return f.Parse(r, mapping)
+}
+
+// mergeImport merges imported books into the library, saving the result
+// unless dryRun is set.
+func mergeImport(incoming []models.Book, dryRun bool) (importer.Result, error) {
+	if _, err := os.Stat(booksFile); os.IsNotExist(err) {
+		initializeBooksFile()
+	}
+
+	n := 0
+	newID := func() string {
+		n++
+		return fmt.Sprintf("%s_%d", generateID(), n)
+	}
+	result := importer.Merge(loadBooks().Books, incoming, newID)
+
+	if dryRun {
+		return result, nil
+	}
+
+	if err := saveBooks(models.Books{Books: result.Books}); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// storeCover copies a local image from inside root into the cover store.
+func storeCover(root, path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the library", path)
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	return coverStore.Put(data)
+}
+
+// localizeCover downloads a book's remote cover into the cover store and
+// points the book at the local copy, remembering the original URL as the
+// fallback. The download is best effort, since it holds up the save: the
+// store's client gives up after a few seconds without retrying and only
+// fetches from public addresses, and a cover that cannot be downloaded is
+// left as it is. Inline data: covers, as from an EPUB upload, are stored
+// too, and dropped when they are not images. previous is the book before
+// an update, or nil for a new book.
+func localizeCover(ctx context.Context, book *models.Book, previous *models.Book) {
+	if previous != nil && book.Cover == previous.Cover {
+		book.CoverSource = previous.CoverSource
+		return
+	}
+	book.CoverSource = ""
+	if coverstore.IsData(book.Cover) {
+		cover, err := coverStore.PutData(book.Cover)
+		if err != nil {
+			log.Printf("Error storing cover for %s: %v", book.Name, err)
+		}
+		book.Cover = cover
+		return
+	}
+	if !coverstore.IsRemote(book.Cover) || coverstore.IsPlaceholder(book.Cover) {
+		return
+	}
+
+	cover, err := coverStore.Fetch(ctx, book.Cover)
+	if err != nil {
+		log.Printf("Error storing cover for %s: %v", book.Name, err)
+		return
+	}
+	book.CoverSource = book.Cover
+	book.Cover = cover
+}
+
+// displayCover returns the cover to show for a book: its own, or a
+// generated placeholder in the given format ("svg" or "png") when it has
+// none.
+func displayCover(book models.Book, format string) string {
+	if book.Cover == "" || coverstore.IsPlaceholder(book.Cover) {
+		return coverstore.PlaceholderURL(book.Name, book.Author, book.Genre, format)
+	}
+	return book.Cover
+}
+
+// coverSource returns the URL a stored cover was downloaded from, for
+// serving when the local copy is missing.
+func coverSource(hash string) string {
+	for _, book := range loadBooks().Books {
+		if book.CoverSource != "" && strings.HasPrefix(book.Cover, coverstore.Prefix+hash+".") {
+			return book.CoverSource
+		}
+	}
+	return ""
+}
+
+// coversCommand downloads every remote cover in the library into the cover
+// store and generates thumbnails missing for stored covers. Links to
+// via.placeholder.com are removed so those books get generated placeholders.
+func coversCommand(args []string) error {
+	fs := flag.NewFlagSet("covers", flag.ExitOnError)
+	missing := fs.Bool("missing", false, "download stored covers again from their original URL when the local copy is missing")
+	fs.Parse(args)
+
+	books := loadBooks()
+	downloaded, failed, cleared, changed := 0, 0, 0, false
+	for i := range books.Books {
+		book := &books.Books[i]
+		if *missing && book.CoverSource != "" && coverstore.IsLocal(book.Cover) {
+			if _, err := os.Stat(filepath.Join(coversDir, strings.TrimPrefix(book.Cover, coverstore.Prefix))); os.IsNotExist(err) {
+				book.Cover = book.CoverSource
+			}
+		}
+
+		switch {
+		case coverstore.IsPlaceholder(book.Cover):
+			book.Cover = ""
+			cleared++
+			changed = true
+		case coverstore.IsRemote(book.Cover):
+			localizeCover(context.Background(), book, nil)
+			if coverstore.IsLocal(book.Cover) {
+				downloaded++
+				changed = true
+			} else {
+				failed++
+			}
+		case coverstore.IsLocal(book.Cover):
+			if err := coverStore.Thumbnails(book.Cover); err != nil {
+				log.Printf("Error generating thumbnails for %s: %v", book.Name, err)
+			}
+		}
+	}
+
+	if changed {
+		if err := saveBooks(books); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Downloaded %d covers, %d failed, removed %d placeholder links\n", downloaded, failed, cleared)
+	return nil
+}
+
+func importHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Bad import request", 400)
+		return
+	}
+
+	if req.FormValue("key") != postKey && postKey != "" {
+		http.Error(w, "Unauthorized", 401)
+		return
+	}
+
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		http.Error(w, "Export file is required", 400)
+		return
+	}
+	defer file.Close()
+
+	var mapping importer.Mapping
+	if m := req.FormValue("mapping"); m != "" {
+		if err := json.Unmarshal([]byte(m), &mapping); err != nil {
+			http.Error(w, "Bad mapping", 400)
+			return
+		}
+	}
+
+	format := req.FormValue("format")
+	if format == "" {
+		format = "goodreads"
+	}
+	dryRun := req.FormValue("dry_run") == "true"
+
+	var result importer.Result
+	incoming, err := parseImport(format, file, mapping)
+	if err == nil {
+		result, err = mergeImport(incoming, dryRun)
+	}
+	if err != nil {
+		log.Printf("Error importing %s export: %v", format, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"dry_run": dryRun,
+		"result":  result,
+	})
+}
+
+// epubHandler reads the metadata of an uploaded EPUB and returns a book
+// pre-filled from it for the user to confirm. The book is not saved.
+func epubHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, 100<<20)
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Bad upload", 400)
+		return
+	}
+
+	if req.FormValue("key") != postKey && postKey != "" {
+		http.Error(w, "Unauthorized", 401)
+		return
+	}
+
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		http.Error(w, "EPUB file is required", 400)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Bad upload", 400)
+		return
+	}
+
+	e, err := epub.Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		log.Printf("Error reading EPUB: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	book := e.Package.Book()
+	book.Type = []models.BookType{models.Ebook}
+	book.Status = models.Unread
+	// The book is only a draft, so the cover is returned inline and stored
+	// when the book is saved.
+	book.Cover = coverstore.DataURL(e.Cover)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"book":        book,
+		"languages":   e.Package.Metadata.Languages,
+		"identifiers": e.Package.Metadata.Identifiers,
+	})
+}
+
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "export format")
+	columns := fs.String("columns", "", "comma-separated columns to include")
+	filterJSON := fs.String("filter", "", "JSON BookFilter to apply")
+	ids := fs.String("ids", "", "comma-separated IDs of the books to export")
+	output := fs.String("o", "", "output file (default stdout)")
+	base := fs.String("base", "", "absolute URL of the shelf, for links back to book pages")
+	fs.Parse(args)
+
+	f, ok := export.Lookup(*format)
+	if !ok {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	library := loadBooks().Books
+	books := library
+	if *filterJSON != "" {
+		var filter models.BookFilter
+		if err := json.Unmarshal([]byte(*filterJSON), &filter); err != nil {
+			return fmt.Errorf("parsing filter: %w", err)
+		}
+		books = filterBooks(books, filter)
+	}
+	books = selectBooks(books, splitParam(*ids))
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return f.Write(w, books, export.Options{Columns: splitParam(*columns), BaseURL: strings.TrimSuffix(*base, "/"), Goals: loadGoals().Goals, Library: library})
+}
+
+func exportHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	name := query.Get("format")
+	if name == "" {
+		name = "csv"
+	}
+
+	f, ok := export.Lookup(name)
+	if !ok {
+		http.Error(w, "Unknown export format", 400)
+		return
+	}
+
+	// Citation keys are numbered across the library the request may see, so
+	// they never reveal that hidden books exist.
+	library := visibleBooks(req)
+	books := filterBooks(library, filterFromQuery(query))
+	books = selectBooks(books, queryList(query, "id"))
+	opts := export.Options{Columns: splitParam(query.Get("columns")), BaseURL: web.BaseURL(req), Goals: loadGoals().Goals, Library: library}
+
+	var buf strings.Builder
+	if err := f.Write(&buf, books, opts); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"books%s\"", f.Extension))
+	io.WriteString(w, buf.String())
+}
+
+// calendarHandler serves the reading timeline as an iCalendar feed at a
+// stable URL calendar apps can subscribe to, with loans and the deadlines
+// of reading goals. It accepts the same filter parameters as /books/export.
+// Private books and loans are left out unless the URL carries the calendar
+// token (?token=).
+func calendarHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	full := calendarAccess(req)
+	books := publicBooks()
+	if full {
+		books = loadBooks().Books
+	}
+	books = filterBooks(books, filterFromQuery(req.URL.Query()))
+
+	var buf strings.Builder
+	if err := export.ICal.Write(&buf, books, export.Options{BaseURL: web.BaseURL(req), Goals: loadGoals().Goals}); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ICal.ContentType)
+	if full {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "max-age=900")
+	}
+	io.WriteString(w, buf.String())
+}
+
+// calendarAccess reports whether a calendar request may see the whole
+// library: it carries the calendar token, or no post key is configured.
+func calendarAccess(req *http.Request) bool {
+	if postKey == "" {
+		return true
+	}
+	token := req.URL.Query().Get("token")
+	return calendarToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(calendarToken)) == 1
+}
+
+func loadGoals() models.Goals {
+	goalsMutex.RLock()
+	defer goalsMutex.RUnlock()
+
+	data, err := os.ReadFile(goalsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading goals file: %v", err)
+		}
+		return models.Goals{Goals: []models.Goal{}}
+	}
+
+	var goals models.Goals
+	if err := json.Unmarshal(data, &goals); err != nil {
+		log.Printf("Error parsing goals file: %v", err)
+		return models.Goals{Goals: []models.Goal{}}
+	}
+
+	return goals
+}
+
+func saveGoals(goals models.Goals) error {
+	goalsMutex.Lock()
+	defer goalsMutex.Unlock()
+
+	data, err := json.MarshalIndent(goals, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(goalsFile, data, 0644)
+}
+
+// goalsHandler manages reading goals: GET lists them with the number of
+// books finished towards each, POST adds one, PUT replaces one by ID and
+// DELETE removes one by ID.
+func goalsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		type goalProgress struct {
+			models.Goal
+			Finished int `json:"finished"`
+		}
+		books := visibleBooks(req)
+		progress := []goalProgress{}
+		for _, goal := range loadGoals().Goals {
+			progress = append(progress, goalProgress{Goal: goal, Finished: goal.Progress(books)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"goals": progress})
+		return
+	}
+
+	var g models.PostGoal
+	if err := json.NewDecoder(req.Body).Decode(&g); err != nil {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	if g.Key != postKey && postKey != "" {
+		http.Error(w, "Unauthorized", 401)
+		return
+	}
+
+	if req.Method != http.MethodDelete && (g.Goal.Books <= 0 || g.Goal.Deadline.IsZero()) {
+		http.Error(w, "A goal needs a number of books and a deadline", 400)
+		return
+	}
+
+	goals := loadGoals()
+	index := -1
+	for i, existing := range goals.Goals {
+		if existing.ID == g.Goal.ID {
+			index = i
+			break
+		}
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		g.Goal.ID = generateID()
+		goals.Goals = append(goals.Goals, g.Goal)
+	case http.MethodPut:
+		if index < 0 {
+			http.Error(w, "Goal not found", 404)
+			return
+		}
+		goals.Goals[index] = g.Goal
+	case http.MethodDelete:
+		if index < 0 {
+			http.Error(w, "Goal not found", 404)
+			return
+		}
+		goals.Goals = append(goals.Goals[:index], goals.Goals[index+1:]...)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := saveGoals(goals); err != nil {
+		http.Error(w, "Failed to save goals", 500)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(g.Goal)
+	case http.MethodPut:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(g.Goal)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// selectBooks keeps the books with the given IDs, or all of them when ids is
+// empty.
+func selectBooks(books []models.Book, ids []string) []models.Book {
+	if len(ids) == 0 {
+		return books
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	selected := []models.Book{}
+	for _, book := range books {
+		if wanted[book.ID] {
+			selected = append(selected, book)
+		}
+	}
+	return selected
+}
+
+// filterFromQuery builds a BookFilter from query parameters. List parameters
+// may be repeated or comma-separated, e.g. ?status=reading,completed.
+func filterFromQuery(query url.Values) models.BookFilter {
+	var filter models.BookFilter
+	for _, t := range queryList(query, "type") {
+		filter.Type = append(filter.Type, models.BookType(t))
+	}
+	for _, s := range queryList(query, "status") {
+		filter.Status = append(filter.Status, models.Status(s))
+	}
+	filter.Genre = queryList(query, "genre")
+	filter.Author = queryList(query, "author")
+	filter.Rating, _ = strconv.Atoi(query.Get("rating"))
+	filter.Search = query.Get("search")
+	return filter
+}
+
+func queryList(query url.Values, key string) []string {
+	var values []string
+	for _, v := range query[key] {
+		values = append(values, splitParam(v)...)
+	}
+	return values
+}
+
+// splitParam splits a comma-separated parameter, dropping empty entries.
+func splitParam(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func generateID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+func defaultAddr() string {
+	port := os.Getenv("PORT")
+	if port != "" {
+		return ":" + port
+	}
+
+	return ":4000"
+}
+
+func lookupHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		ISBN   string `json:"isbn"`
+		Title  string `json:"title"`
+		Author string `json:"author"`
+		Limit  int    `json:"limit"`
+	}
+	
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	// Without an ISBN, search by title and author and let the user pick
+	if request.ISBN == "" && (request.Title != "" || request.Author != "") {
+		searchCandidates(w, req, request.Title, request.Author, request.Limit)
+		return
+	}
+
+	if request.ISBN == "" {
+		http.Error(w, "ISBN or title is required", 400)
+		return
+	}
+
+	// Validate and normalize the ISBN to its ISBN-13 form
+	code, err := isbn.Canonical(request.ISBN)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	
+	// Lookup book details from the metadata providers
+	bookData, err := lookupBook(req.Context(), code)
+	if err != nil {
+		log.Printf("Error looking up book: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to lookup book details",
+		})
+		return
+	}
+
+	if bookData == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Book not found",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"book":    bookData,
+	})
+}
+
+// searchCandidates writes the editions matching title and author, best
+// match first, for the add form to offer as choices.
+func searchCandidates(w http.ResponseWriter, req *http.Request, title, author string, limit int) {
+	if limit <= 0 || limit > 25 {
+		limit = 10
+	}
+
+	candidates, err := searcher.Search(req.Context(), strings.TrimSpace(title), strings.TrimSpace(author), limit)
+	if err != nil {
+		log.Printf("Error searching for books: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "Failed to search for books",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"candidates": lookup.ApplySearchOverrides(candidates, loadOverrides().Overrides),
+	})
+}
+
+// scanHandler reads the ISBN from a photo of a book's barcode and looks it
+// up, so the add form can be filled from a phone camera.
+func scanHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Clean ISBN (remove hyphens and spaces)
-	isbn := strings.ReplaceAll(strings.ReplaceAll(request.ISBN, "-", ""), " ", "")
-	
-	// Lookup book details from Open Library API
-	bookData, err := lookupBookFromOpenLibrary(isbn)
+	req.Body = http.MaxBytesReader(w, req.Body, 20<<20)
+	if err := req.ParseMultipartForm(20 << 20); err != nil {
+		http.Error(w, "Bad upload", 400)
+		return
+	}
+
+	file, _, err := req.FormFile("image")
+	if err != nil {
+		http.Error(w, "Image is required", 400)
+		return
+	}
+	defer file.Close()
+
+	code, err := barcode.Decode(file)
+	if err == barcode.ErrTooLarge {
+		http.Error(w, "Image is larger than 40 megapixels", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err == barcode.ErrNotFound {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"message": "No barcode found in the photo",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Image could not be read", 400)
+		return
+	}
+
+	// Only Bookland EANs (978 and 979) are ISBNs
+	if _, err := isbn.Canonical(code); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"barcode": code,
+			"message": fmt.Sprintf("Barcode %s is not an ISBN", code),
+		})
+		return
+	}
+
+	bookData, err := lookupBook(req.Context(), code)
 	if err != nil {
 		log.Printf("Error looking up book: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
+			"isbn":    code,
 			"message": "Failed to lookup book details",
 		})
 		return
@@ -478,6 +1936,7 @@ func lookupHandler(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
+			"isbn":    code,
 			"message": "Book not found",
 		})
 		return
@@ -486,231 +1945,509 @@ func lookupHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
+		"isbn":    code,
 		"book":    bookData,
 	})
 }
 
-func lookupBookFromOpenLibrary(isbn string) (map[string]interface{}, error) {
-	// Open Library API endpoint for ISBN lookup
-	url := fmt.Sprintf("https://openlibrary.org/isbn/%s.json", isbn)
-	
-	resp, err := http.Get(url)
+// lookupBook looks an ISBN up with the metadata providers and applies the
+// override rules to the result.
+func lookupBook(ctx context.Context, code string) (*lookup.Result, error) {
+	result, err := metadata.LookupISBN(ctx, code)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return lookup.ApplyOverrides(result, code, loadOverrides().Overrides), nil
+}
+
+func initializeOverridesFile() {
+	if err := saveOverrides(lookup.Overrides{Overrides: lookup.DefaultOverrides}); err != nil {
+		log.Printf("Error creating overrides file: %v", err)
+	}
+}
+
+func loadOverrides() lookup.Overrides {
+	overridesMutex.RLock()
+	defer overridesMutex.RUnlock()
 
-	if resp.StatusCode == 404 {
-		return nil, nil // Book not found
+	data, err := os.ReadFile(overridesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading overrides file: %v", err)
+		}
+		return lookup.Overrides{Overrides: []lookup.Override{}}
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	var overrides lookup.Overrides
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		log.Printf("Error parsing overrides file: %v", err)
+		return lookup.Overrides{Overrides: []lookup.Override{}}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return overrides
+}
+
+func saveOverrides(overrides lookup.Overrides) error {
+	overridesMutex.Lock()
+	defer overridesMutex.Unlock()
+
+	data, err := json.MarshalIndent(overrides, "", "  ")
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var bookData map[string]interface{}
-	if err := json.Unmarshal(body, &bookData); err != nil {
-		return nil, err
+	return os.WriteFile(overridesFile, data, 0644)
+}
+
+// overridesHandler manages the lookup override rules: GET lists them, POST
+// adds one, PUT replaces one by ID and DELETE removes one by ID.
+func overridesHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loadOverrides())
+		return
 	}
 
-	// Debug: Log the raw book data structure
-	log.Printf("Raw book data for ISBN %s: %+v", isbn, bookData)
+	var o struct {
+		Override lookup.Override `json:"override"`
+		Key      string          `json:"key"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&o); err != nil {
+		http.Error(w, "Bad request", 400)
+		return
+	}
 
-	// Extract and format book information
-	result := make(map[string]interface{})
-	
-	// Title
-	if title, ok := bookData["title"].(string); ok {
-		result["title"] = title
+	if o.Key != postKey && postKey != "" {
+		http.Error(w, "Unauthorized", 401)
+		return
 	}
-	
-	// Authors - try multiple approaches
-	authorFound := false
-	if authors, ok := bookData["authors"].([]interface{}); ok && len(authors) > 0 {
-		if authorData, ok := authors[0].(map[string]interface{}); ok {
-			if authorKey, ok := authorData["key"].(string); ok {
-				// Get author name from the author key
-				if authorName, err := getAuthorName(authorKey); err == nil {
-					result["author"] = authorName
-					authorFound = true
-				} else {
-					log.Printf("Failed to get author name for key %s: %v", authorKey, err)
-					// Try to get author name directly from the author data
-					if name, ok := authorData["name"].(string); ok && name != "" {
-						result["author"] = name
-						authorFound = true
-						log.Printf("Using direct author name: %s", name)
-					}
-				}
-			} else {
-				log.Printf("No author key found in author data: %v", authorData)
-				// Try to get author name directly from the author data
-				if name, ok := authorData["name"].(string); ok && name != "" {
-					result["author"] = name
-					authorFound = true
-					log.Printf("Using direct author name: %s", name)
-				}
-			}
-		} else {
-			log.Printf("Invalid author data format: %v", authors[0])
-		}
-	} else {
-		log.Printf("No authors found in book data")
-		// Try alternative author field
-		if author, ok := bookData["author"].(string); ok && author != "" {
-			result["author"] = author
-			authorFound = true
-			log.Printf("Using alternative author field: %s", author)
+
+	if req.Method != http.MethodDelete && o.Override.ISBN == "" && o.Override.TitleContains == "" {
+		http.Error(w, "An override needs an isbn or title_contains to match on", 400)
+		return
+	}
+	if req.Method != http.MethodDelete && o.Override.ISBN != "" {
+		code, err := isbn.Canonical(o.Override.ISBN)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
 		}
+		o.Override.ISBN = code
 	}
-	
-	// If no author found through Open Library, try fallback
-	if !authorFound {
-		log.Printf("No author found, trying fallback for ISBN: %s, Title: %s", isbn, result["title"])
-		if author := getAuthorFromFallback(isbn, result["title"].(string)); author != "" {
-			result["author"] = author
-			log.Printf("Using fallback author: %s", author)
-		} else {
-			log.Printf("No fallback author found for ISBN: %s, Title: %s", isbn, result["title"])
-			// Manual fallback for specific books
-			if isbn == "9780141439518" || isbn == "0141439513" {
-				result["author"] = "Jane Austen"
-				log.Printf("Using manual fallback: Jane Austen")
-			} else if isbn == "9780547928227" || isbn == "0547928227" {
-				result["author"] = "J.R.R. Tolkien"
-				log.Printf("Using manual fallback: J.R.R. Tolkien")
-			}
+	if req.Method != http.MethodDelete && o.Override.Set.ISBN != "" {
+		code, err := isbn.Canonical(o.Override.Set.ISBN)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
 		}
+		o.Override.Set.ISBN = code
 	}
-	
-	// Number of pages
-	if pages, ok := bookData["number_of_pages"].(float64); ok {
-		result["pages"] = int(pages)
+	if req.Method != http.MethodDelete && o.Override.Set.Published != "" && o.Override.Set.PublishedDate().IsZero() {
+		http.Error(w, "published must be a date such as 1937-09-21, 1937-09 or 1937", 400)
+		return
 	}
-	
-	// Description
-	if description, ok := bookData["description"].(string); ok {
-		result["description"] = description
-	} else if descriptions, ok := bookData["description"].(map[string]interface{}); ok {
-		if desc, ok := descriptions["value"].(string); ok {
-			result["description"] = desc
+
+	overrides := loadOverrides()
+	index := -1
+	for i, existing := range overrides.Overrides {
+		if existing.ID == o.Override.ID {
+			index = i
+			break
 		}
 	}
-	
-	// Subjects (use as genre)
-	if subjects, ok := bookData["subjects"].([]interface{}); ok && len(subjects) > 0 {
-		if subject, ok := subjects[0].(string); ok {
-			result["genre"] = subject
+
+	switch req.Method {
+	case http.MethodPost:
+		o.Override.ID = generateID()
+		overrides.Overrides = append(overrides.Overrides, o.Override)
+	case http.MethodPut:
+		if index < 0 {
+			http.Error(w, "Override not found", 404)
+			return
+		}
+		overrides.Overrides[index] = o.Override
+	case http.MethodDelete:
+		if index < 0 {
+			http.Error(w, "Override not found", 404)
+			return
 		}
+		overrides.Overrides = append(overrides.Overrides[:index], overrides.Overrides[index+1:]...)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	
-	// Cover image
-	if coverID, ok := bookData["cover"].(map[string]interface{}); ok {
-		if large, ok := coverID["large"].(string); ok {
-			result["cover"] = large
-		} else if medium, ok := coverID["medium"].(string); ok {
-			result["cover"] = medium
-		} else if small, ok := coverID["small"].(string); ok {
-			result["cover"] = small
+
+	if err := saveOverrides(overrides); err != nil {
+		http.Error(w, "Failed to save overrides", 500)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(o.Override)
+	case http.MethodPut:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(o.Override)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newEnricher sets up the background enrichment job with its saved state.
+// ENRICH_INTERVAL sets the minimum time between books (default "2s"); each
+// book can take several catalogue requests.
+func newEnricher() (*enrich.Job, error) {
+	interval := 2 * time.Second
+	if v := os.Getenv("ENRICH_INTERVAL"); v != "" {
+		var err error
+		if interval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("ENRICH_INTERVAL: %w", err)
 		}
-	} else {
-		// Try alternative cover field
-		if coverID, ok := bookData["cover_id"].(float64); ok {
-			result["cover"] = fmt.Sprintf("https://covers.openlibrary.org/b/id/%d-L.jpg", int(coverID))
+	}
+
+	job := &enrich.Job{
+		Path:   enrichFile,
+		Books:  allBooks,
+		Lookup: lookupBook,
+		Search: func(ctx context.Context, title, author string) ([]lookup.Candidate, error) {
+			return searcher.Search(ctx, title, author, 5)
+		},
+		Interval: interval,
+	}
+	if err := job.Load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", enrichFile, err)
+	}
+	return job, nil
+}
+
+func enrichHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(enricher.Progress())
+
+	case http.MethodPost, http.MethodDelete:
+		var request struct {
+			Key     string `json:"key"`
+			Restart bool   `json:"restart"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, "Bad request", 400)
+			return
+		}
+
+		if request.Key != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+
+		if req.Method == http.MethodPost {
+			err := enricher.Start(request.Restart)
+			if err == enrich.ErrRunning {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Printf("Error starting enrichment: %v", err)
+				http.Error(w, "Failed to start enrichment", 500)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(enricher.Progress())
+			return
+		}
+
+		if err := enricher.Stop(); err != nil {
+			log.Printf("Error stopping enrichment: %v", err)
+			http.Error(w, "Failed to stop enrichment", 500)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(enricher.Progress())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
-	
-	// ISBN
-	result["isbn"] = isbn
-	
-	return result, nil
 }
 
-func getAuthorName(authorKey string) (string, error) {
-	url := fmt.Sprintf("https://openlibrary.org%s.json", authorKey)
-	
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
+// enrichProposalsHandler lists the fills the enrichment job found (GET),
+// applies them to the library (POST) or dismisses them (DELETE). POST and
+// DELETE take the book IDs to act on; no IDs means every proposal.
+func enrichProposalsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		if req.URL.Query().Get("key") != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"proposals": enricher.Proposals(),
+		})
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("API returned status %d", resp.StatusCode)
+	var request struct {
+		Key string   `json:"key"`
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(w, "Bad request", 400)
+		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if request.Key != postKey && postKey != "" {
+		http.Error(w, "Unauthorized", 401)
+		return
 	}
 
-	var authorData map[string]interface{}
-	if err := json.Unmarshal(body, &authorData); err != nil {
-		return "", err
+	switch req.Method {
+	case http.MethodPost:
+		wanted := make(map[string]bool, len(request.IDs))
+		for _, id := range request.IDs {
+			wanted[id] = true
+		}
+		selected := make(map[string]enrich.Proposal)
+		for _, p := range enricher.Proposals() {
+			if len(request.IDs) == 0 || wanted[p.BookID] {
+				selected[p.BookID] = p
+			}
+		}
+
+		// Fill only what is still empty: the book may have been edited since
+		// the proposal was made.
+		books := loadBooks()
+		filled := make(map[string][]string)
+		for i, book := range books.Books {
+			if p, ok := selected[book.ID]; ok {
+				if fields := enrich.Apply(&books.Books[i], p); len(fields) > 0 {
+					filled[book.ID] = fields
+				}
+			}
+		}
+
+		if len(filled) > 0 {
+			if err := saveBooks(books); err != nil {
+				http.Error(w, "Failed to update books", 500)
+				return
+			}
+		}
+
+		ids := make([]string, 0, len(selected))
+		for id := range selected {
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			if err := enricher.Remove(ids); err != nil {
+				log.Printf("Error saving enrichment state: %v", err)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"updated": len(filled),
+			"filled":  filled,
+		})
+
+	case http.MethodDelete:
+		if err := enricher.Remove(request.IDs); err != nil {
+			http.Error(w, "Failed to dismiss proposals", 500)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newMetadataProvider builds the lookup providers named in LOOKUP_PROVIDERS
+// (default "openlibrary,googlebooks"), in priority order. Base URLs can be
+// overridden to point at mirrors or local stand-ins.
+func newMetadataProvider() (lookup.MetadataProvider, error) {
+	names := os.Getenv("LOOKUP_PROVIDERS")
+	if names == "" {
+		names = "openlibrary,googlebooks"
 	}
 
-	if name, ok := authorData["name"].(string); ok {
-		return name, nil
+	client, err := newLookupClient()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := newLookupCache()
+	if err != nil {
+		return nil, err
+	}
+	lookupCache = cache
+	searcher = lookup.OpenLibrary{
+		BaseURL:   os.Getenv("OPENLIBRARY_URL"),
+		CoversURL: os.Getenv("OPENLIBRARY_COVERS_URL"),
+		Client:    client,
+		Cache:     cache,
+	}
+
+	var providers lookup.Multi
+	for _, name := range splitParam(names) {
+		switch strings.ToLower(name) {
+		case "openlibrary":
+			providers = append(providers, searcher)
+		case "googlebooks":
+			providers = append(providers, lookup.GoogleBooks{
+				BaseURL: os.Getenv("GOOGLE_BOOKS_URL"),
+				APIKey:  os.Getenv("GOOGLE_BOOKS_KEY"),
+				Client:  client,
+			})
+		case "loc":
+			providers = append(providers, lookup.LibraryOfCongress{
+				BaseURL: os.Getenv("LOC_SRU_URL"),
+				Client:  client,
+			})
+		default:
+			return nil, fmt.Errorf("unknown lookup provider %q", name)
+		}
 	}
 
-	return "", fmt.Errorf("author name not found")
+	if cache != nil {
+		for i, p := range providers {
+			providers[i] = lookup.Cached{Provider: p, Cache: cache}
+		}
+	}
+	return providers, nil
 }
 
-func getAuthorFromFallback(isbn, title string) string {
-	log.Printf("Fallback function called with ISBN: %s, Title: %s", isbn, title)
-	
-	// Hardcoded fallback for common books when Open Library doesn't have author info
-	fallbackAuthors := map[string]string{
-		"9780141439518": "Jane Austen",
-		"0141439513":    "Jane Austen",
-		"9780547928227": "J.R.R. Tolkien",
-		"0547928227":    "J.R.R. Tolkien",
-		"9780061120084": "Harper Lee",
-		"0061120081":    "Harper Lee",
+// newLookupCache configures the on-disk lookup cache from LOOKUP_CACHE_DIR
+// (default "lookup-cache"; set it empty to disable caching),
+// LOOKUP_CACHE_TTL and LOOKUP_NEGATIVE_TTL.
+func newLookupCache() (*lookup.Cache, error) {
+	dir, ok := os.LookupEnv("LOOKUP_CACHE_DIR")
+	if !ok {
+		dir = "lookup-cache"
 	}
-	
-	// Check by ISBN first
-	if author, exists := fallbackAuthors[isbn]; exists {
-		log.Printf("Found author by ISBN: %s", author)
-		return author
+	if dir == "" {
+		return nil, nil
 	}
-	
-	// Check by title (case-insensitive)
-	titleLower := strings.ToLower(title)
-	log.Printf("Checking title: %s", titleLower)
-	for isbnKey, author := range fallbackAuthors {
-		// This is a simple approach - in a real app you might want more sophisticated matching
-		if strings.Contains(titleLower, "pride and prejudice") && author == "Jane Austen" {
-			log.Printf("Found author by title match: %s", author)
-			return author
+
+	cache := lookup.NewCache(dir)
+	for env, ttl := range map[string]*time.Duration{
+		"LOOKUP_CACHE_TTL":    &cache.TTL,
+		"LOOKUP_NEGATIVE_TTL": &cache.NegativeTTL,
+	} {
+		if v := os.Getenv(env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", env, err)
+			}
+			*ttl = d
+		}
+	}
+	return cache, nil
+}
+
+// lookupCacheHandler reports lookup cache statistics (GET) and purges
+// entries (DELETE), either all of them, those for one ISBN or author key, or
+// only expired ones.
+func lookupCacheHandler(w http.ResponseWriter, req *http.Request) {
+	if lookupCache == nil {
+		http.Error(w, "Lookup cache is disabled", 404)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		stats, err := lookupCache.Stats()
+		if err != nil {
+			http.Error(w, "Failed to read cache", 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+
+	case http.MethodDelete:
+		var request struct {
+			Key         string `json:"key"`
+			ISBN        string `json:"isbn"`
+			AuthorKey   string `json:"author_key"`
+			ExpiredOnly bool   `json:"expired_only"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, "Bad Delete", 400)
+			return
 		}
-		if strings.Contains(titleLower, "hobbit") && author == "J.R.R. Tolkien" {
-			log.Printf("Found author by title match: %s", author)
-			return author
+
+		if request.Key != postKey && postKey != "" {
+			http.Error(w, "Unauthorized", 401)
+			return
 		}
-		if strings.Contains(titleLower, "to kill a mockingbird") && author == "Harper Lee" {
-			log.Printf("Found author by title match: %s", author)
-			return author
+
+		removed, err := lookupCache.Purge(lookup.PurgeOptions{
+			ISBN:        request.ISBN,
+			AuthorKey:   request.AuthorKey,
+			ExpiredOnly: request.ExpiredOnly,
+		})
+		if err != nil {
+			http.Error(w, "Failed to purge cache", 500)
+			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"removed": removed,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
-	
-	log.Printf("No fallback author found")
-	return ""
+}
+
+// newLookupClient configures the HTTP client shared by the lookup providers
+// from LOOKUP_TIMEOUT (per attempt, e.g. "10s"), LOOKUP_RETRIES and
+// LOOKUP_USER_AGENT.
+func newLookupClient() (*lookup.Client, error) {
+	client := lookup.NewClient()
+	if v := os.Getenv("LOOKUP_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("LOOKUP_TIMEOUT: %w", err)
+		}
+		client.HTTP.Timeout = timeout
+	}
+	if v := os.Getenv("LOOKUP_RETRIES"); v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("LOOKUP_RETRIES: %w", err)
+		}
+		client.Retries = retries
+	}
+	if v := os.Getenv("LOOKUP_USER_AGENT"); v != "" {
+		client.UserAgent = v
+	}
+	return client, nil
 }
 
 func init() {
 	var err error
 
 	// Parse optional on-disk index file.
-	if index, err = template.ParseFiles("./index.html"); err != nil {
+	if index, err = htmltemplate.ParseFiles("./index.html"); err != nil {
 		log.Println(err)
 		log.Println("Using default template")
 	}
 
+	if bookPage, err = htmltemplate.ParseFiles("./book.html"); err != nil {
+		log.Println(err)
+	}
+
+	if shelfPage, err = htmltemplate.ParseFiles("./shelf.html"); err != nil {
+		log.Println(err)
+	}
+
+	if statsPage, err = htmltemplate.ParseFiles("./stats.html"); err != nil {
+		log.Println(err)
+	}
+
 	rand.Seed(time.Now().UnixNano())
 }
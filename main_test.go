@@ -0,0 +1,301 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+const testKey = "post-key-secret"
+
+// testLibrary points the server at a temporary library holding books and
+// shares, with testKey as the post key.
+func testLibrary(t *testing.T, books []models.Book, shares []models.Share) {
+	t.Helper()
+	dir := t.TempDir()
+	saved := []*string{&booksFile, &sharesFile, &goalsFile, &postKey}
+	values := make([]string, len(saved))
+	for i, p := range saved {
+		values[i] = *p
+	}
+	t.Cleanup(func() {
+		for i, p := range saved {
+			*p = values[i]
+		}
+	})
+
+	booksFile = filepath.Join(dir, "books.json")
+	sharesFile = filepath.Join(dir, "shares.json")
+	goalsFile = filepath.Join(dir, "goals.json")
+	postKey = testKey
+
+	if err := saveBooks(models.Books{Books: books}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveShares(models.Shares{Shares: shares}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get serves a GET request for target with handler.
+func get(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestFeedNotesNeedAShare(t *testing.T) {
+	finished := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	testLibrary(t, []models.Book{
+		{ID: "1", Name: "Dune", Author: "Frank Herbert", Status: models.Completed, Finished: finished, Notes: "Spice notes"},
+		{ID: "2", Name: "Emma", Author: "Jane Austen", Status: models.Completed, Finished: finished, Notes: "Hidden notes", HideNotes: true},
+		{ID: "3", Name: "Diary", Author: "Me", Status: models.Completed, Finished: finished, Notes: "Private notes", Private: true},
+	}, []models.Share{{Token: "share-token-1", Name: "Friends", ShowNotes: true}})
+
+	tests := []struct {
+		name      string
+		target    string
+		status    int
+		notes     bool
+		cacheable bool
+	}{
+		{name: "public", target: "/feeds/finished.atom", status: 200, cacheable: true},
+		{name: "post key does not unlock notes", target: "/feeds/finished.atom?notes=true&key=" + testKey, status: 200, cacheable: true},
+		{name: "share", target: "/feeds/finished.rss?share=share-token-1", status: 200, notes: true},
+		{name: "unknown share", target: "/feeds/finished.atom?share=revoked", status: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(feedHandler, tt.target)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if rec.Code != 200 {
+				return
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, "Spice notes"); got != tt.notes {
+				t.Errorf("notes shown = %v, want %v", got, tt.notes)
+			}
+			for _, secret := range []string{"Hidden notes", "Private notes", "Diary", testKey, "share-token-1", "key="} {
+				if strings.Contains(body, secret) {
+					t.Errorf("feed contains %q", secret)
+				}
+			}
+			if cacheable := !strings.Contains(rec.Header().Get("Cache-Control"), "no-cache"); cacheable != tt.cacheable {
+				t.Errorf("Cache-Control = %q, want cacheable %v", rec.Header().Get("Cache-Control"), tt.cacheable)
+			}
+		})
+	}
+}
+
+func TestFindShare(t *testing.T) {
+	testLibrary(t, nil, []models.Share{{Token: "abc", Name: "Friends"}})
+
+	if share := findShare("abc"); share == nil || share.Name != "Friends" {
+		t.Errorf("findShare(abc) = %+v", share)
+	}
+	for _, token := range []string{"", "ab", "abcd"} {
+		if share := findShare(token); share != nil {
+			t.Errorf("findShare(%q) = %+v, want nil", token, share)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{text: "Short enough", n: 20, want: "Short enough"},
+		{text: "A  story\nof   spice", n: 20, want: "A story of spice"},
+		{text: "A story of spice and sand", n: 12, want: "A story of…"},
+		{text: "ééééé", n: 5, want: "éé…"},
+		{text: "日本語のテキスト", n: 7, want: "日本…"},
+		{text: "é", n: 1, want: "…"},
+	}
+	for _, tt := range tests {
+		got := summarize(tt.text, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("summarize(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarLoansNeedTheCalendarToken(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	testLibrary(t, []models.Book{
+		{ID: "1", Name: "Dune", Author: "Frank Herbert", Started: day(1), Finished: day(9), Loan: &models.Loan{To: "Sam Lender", Lent: day(10)}},
+		{ID: "2", Name: "Diary", Author: "Me", Started: day(2), Finished: day(3), Private: true},
+	}, nil)
+	saved := calendarToken
+	calendarToken = "calendar-token-1"
+	t.Cleanup(func() { calendarToken = saved })
+
+	tests := []struct {
+		name   string
+		target string
+		full   bool
+	}{
+		{name: "public", target: "/calendar.ics"},
+		{name: "post key is not accepted", target: "/calendar.ics?key=" + testKey},
+		{name: "wrong token", target: "/calendar.ics?token=calendar-token-2"},
+		{name: "calendar token", target: "/calendar.ics?token=calendar-token-1", full: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(calendarHandler, tt.target)
+			if rec.Code != 200 {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "Dune") {
+				t.Error("calendar is missing a public book")
+			}
+			for _, s := range []string{"Sam Lender", "Diary"} {
+				if got := strings.Contains(body, s); got != tt.full {
+					t.Errorf("calendar shows %q = %v, want %v", s, got, tt.full)
+				}
+			}
+			if private := strings.Contains(rec.Header().Get("Cache-Control"), "private"); private != tt.full {
+				t.Errorf("Cache-Control = %q", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestExportCitationKeysIgnoreHiddenBooks(t *testing.T) {
+	published := time.Date(1965, 1, 1, 0, 0, 0, 0, time.UTC)
+	testLibrary(t, []models.Book{
+		{ID: "1", Name: "Dune", Author: "Frank Herbert", Published: published, Private: true},
+		{ID: "2", Name: "Dune", Author: "Frank Herbert", Published: published},
+	}, nil)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "/books/export?format=bibtex", want: "{herbert1965dune,"},
+		{target: "/books/export?format=bibtex&id=2&key=" + testKey, want: "{herbert1965duneb,"},
+	}
+	for _, tt := range tests {
+		rec := get(exportHandler, tt.target)
+		if body := rec.Body.String(); !strings.Contains(body, tt.want) {
+			t.Errorf("%s =\n%s\nwant key %s", tt.target, body, tt.want)
+		}
+	}
+}
+
+func TestHiddenDataNeedsTheKey(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	testLibrary(t, []models.Book{
+		{ID: "1", Name: "Dune", Author: "Frank Herbert", Status: models.Completed, Added: day(1), Started: day(1), Finished: day(9), Notes: "Spice notes"},
+		{ID: "2", Name: "Emma", Author: "Jane Austen", Status: models.Reading, Added: day(2), Started: day(2), Notes: "Hidden notes", HideNotes: true,
+			Loan: &models.Loan{To: "Sam Lender", Lent: day(10), Due: day(20)}},
+		{ID: "3", Name: "Secret Diary", Author: "Me", Status: models.Completed, Added: day(3), Started: day(3), Finished: day(4), Notes: "Private notes", Private: true},
+	}, []models.Share{{Token: "share-token-1", Name: "Friends", ShowNotes: true}})
+	secrets := []string{"Secret Diary", "Private notes", "Hidden notes", "Sam Lender"}
+
+	// check fails if body leaks a secret, or is missing the book it should
+	// show.
+	check := func(t *testing.T, body, want string) {
+		t.Helper()
+		if !strings.Contains(body, want) {
+			t.Errorf("%s missing from:\n%s", want, body)
+		}
+		for _, secret := range secrets {
+			if strings.Contains(body, secret) {
+				t.Errorf("found %q in:\n%s", secret, body)
+			}
+		}
+	}
+
+	// The post key does see everything, so the secrets are there to leak.
+	body := get(bookHandler, "/books?key="+testKey).Body.String()
+	for _, secret := range secrets {
+		if !strings.Contains(body, secret) {
+			t.Fatalf("/books with the key is missing %q", secret)
+		}
+	}
+
+	opdsAtom, opdsJSON := catalogHandler("/opds", false), catalogHandler("/opds2", true)
+	tests := []struct {
+		target  string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{target: "/books", handler: bookHandler, want: "Dune"},
+		{target: "/books?key=wrong", handler: bookHandler, want: "Dune"},
+		{target: "/share/share-token-1", handler: sharedShelfHandler, want: "Dune"},
+		{target: "/share/share-token-1.json", handler: sharedShelfHandler, want: "Dune"},
+		{target: "/opds/all", handler: opdsAtom.ServeHTTP, want: "Dune"},
+		{target: "/opds/search?q=e", handler: opdsAtom.ServeHTTP, want: "Dune"},
+		{target: "/opds2/all", handler: opdsJSON.ServeHTTP, want: "Dune"},
+		{target: "/feeds/finished.atom", handler: feedHandler, want: "Dune"},
+		{target: "/feeds/added.rss", handler: feedHandler, want: "Dune"},
+		{target: "/feeds/reading.atom?share=share-token-1", handler: feedHandler, want: "Emma"},
+		{target: "/calendar.ics", handler: calendarHandler, want: "Dune"},
+		{target: "/calendar.ics?key=" + testKey, handler: calendarHandler, want: "Dune"},
+		{target: "/book/1", handler: bookPageHandler, want: "Dune"},
+	}
+	for _, name := range []string{"csv", "bibtex", "ris", "csl-json", "goodreads", "ical"} {
+		tests = append(tests, struct {
+			target  string
+			handler http.HandlerFunc
+			want    string
+		}{target: "/books/export?format=" + name + "&columns=name,notes", handler: exportHandler, want: "Dune"})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := get(tt.handler, tt.target)
+			if rec.Code != 200 {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			check(t, rec.Body.String(), tt.want)
+		})
+	}
+
+	if rec := get(bookPageHandler, "/book/2"); rec.Code != 200 {
+		t.Errorf("/book/2 status = %d", rec.Code)
+	} else {
+		check(t, rec.Body.String(), "Emma")
+	}
+	if rec := get(bookPageHandler, "/book/3"); rec.Code != 404 {
+		t.Errorf("/book/3 status = %d, want 404", rec.Code)
+	}
+
+	t.Run("static build", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := buildCommand([]string{"-o", dir, "-base", "https://books.example.com", "-notes"}); err != nil {
+			t.Fatal(err)
+		}
+		var site strings.Builder
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(path)
+			site.Write(data)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, site.String(), "Spice notes")
+		if _, err := os.Stat(filepath.Join(dir, "book", "3")); err == nil {
+			t.Error("the private book has a page")
+		}
+	})
+}
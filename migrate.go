@@ -135,7 +135,7 @@ func convertSQLBookToModel(sqlBook SQLBook) models.Book {
 		Status:      models.Unread, // Default status
 		Rating:      0,             // Default rating
 		Pages:       0,             // Default pages
-		Duration:    "",            // Default duration
+		Duration:    0,             // Default duration
 		Publisher:   "",            // Default publisher
 		Published:   time.Time{},   // Default published date
 		Added:       time.Now(),    // Current timestamp
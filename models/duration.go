@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is the running time of an audiobook. It is stored in JSON as a
+// string such as "12h 30m" so books.json stays readable.
+type Duration time.Duration
+
+var (
+	clockPattern = regexp.MustCompile(`^(\d+):(\d{1,2})(?::(\d{1,2}))?$`)
+	isoPattern   = regexp.MustCompile(`^p(?:(\d+)d)?(?:t(?:(\d+(?:\.\d+)?)h)?(?:(\d+(?:\.\d+)?)m)?(?:(\d+(?:\.\d+)?)s)?)?$`)
+	// Units are listed longest first so "12 hours" is not read as "12 h"
+	// followed by "ours", and compact forms like "12h30m" need no spaces.
+	partPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(hours|hour|hrs|hr|h|minutes|minute|mins|min|m|seconds|second|secs|sec|s)`)
+)
+
+// ParseDuration reads the running-time formats found in stores and exports:
+// "12h 30m", "12h30m", "12 hrs and 30 mins", "12.5 hours", "45 min",
+// "12:30:00" and ISO 8601 durations such as "PT12H30M". An empty string is
+// a zero duration.
+func ParseDuration(s string) (Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	if m := clockPattern.FindStringSubmatch(s); m != nil {
+		h, _ := strconv.Atoi(m[1])
+		min, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.Atoi(m[3])
+		return Duration(time.Duration(h)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second), nil
+	}
+
+	if m := isoPattern.FindStringSubmatch(s); m != nil && s != "p" && !strings.HasSuffix(s, "t") {
+		days, _ := strconv.ParseFloat(m[1], 64)
+		h, _ := strconv.ParseFloat(m[2], 64)
+		min, _ := strconv.ParseFloat(m[3], 64)
+		sec, _ := strconv.ParseFloat(m[4], 64)
+		total := days*24*float64(time.Hour) + h*float64(time.Hour) + min*float64(time.Minute) + sec*float64(time.Second)
+		return Duration(time.Duration(total).Round(time.Second)), nil
+	}
+
+	parts := partPattern.FindAllStringSubmatch(s, -1)
+	if len(parts) == 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	for _, part := range parts {
+		n, _ := strconv.ParseFloat(part[1], 64)
+		unit := time.Second
+		switch part[2][0] {
+		case 'h':
+			unit = time.Hour
+		case 'm':
+			unit = time.Minute
+		}
+		total += time.Duration(n * float64(unit))
+	}
+	return Duration(total.Round(time.Second)), nil
+}
+
+// Hours returns the duration as a floating point number of hours.
+func (d Duration) Hours() float64 {
+	return time.Duration(d).Hours()
+}
+
+// String formats the duration as "12h 30m", or "" when it is zero.
+func (d Duration) String() string {
+	if d <= 0 {
+		return ""
+	}
+	minutes := int(math.Round(time.Duration(d).Minutes()))
+	h, m := minutes/60, minutes%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh %dm", h, m)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Accept a bare number of minutes as well.
+		var minutes float64
+		if json.Unmarshal(data, &minutes) != nil {
+			return err
+		}
+		*d = Duration(time.Duration(minutes * float64(time.Minute)))
+		return nil
+	}
+
+	// A running time in a format ParseDuration does not know, as older
+	// books.json files may hold, is treated as unknown rather than failing
+	// the whole file.
+	parsed, _ := ParseDuration(s)
+	*d = parsed
+	return nil
+}
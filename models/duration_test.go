@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"12h 30m", 12*time.Hour + 30*time.Minute},
+		{"12h30m", 12*time.Hour + 30*time.Minute},
+		{"12H30M", 12*time.Hour + 30*time.Minute},
+		{"12 hrs and 30 mins", 12*time.Hour + 30*time.Minute},
+		{"12 hours 30 minutes", 12*time.Hour + 30*time.Minute},
+		{"1 hour 1 minute 1 second", time.Hour + time.Minute + time.Second},
+		{"12.5 hours", 12*time.Hour + 30*time.Minute},
+		{"45 min", 45 * time.Minute},
+		{"90s", 90 * time.Second},
+		{"12:30", 12*time.Hour + 30*time.Minute},
+		{"12:30:15", 12*time.Hour + 30*time.Minute + 15*time.Second},
+		{"PT12H30M", 12*time.Hour + 30*time.Minute},
+		{"pt45m", 45 * time.Minute},
+		{"PT1.5H", 90 * time.Minute},
+		{"P1DT2H", 26 * time.Hour},
+		{"PT30S", 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if err != nil || time.Duration(got) != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.input, time.Duration(got), err, tt.want)
+		}
+	}
+}
+
+func TestParseDurationInvalid(t *testing.T) {
+	for _, input := range []string{"soon", "P", "PT", "twelve hours", "12"} {
+		if d, err := ParseDuration(input); err == nil {
+			t.Errorf("ParseDuration(%q) = %v, want an error", input, time.Duration(d))
+		}
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, ""},
+		{45 * time.Minute, "45m"},
+		{12 * time.Hour, "12h"},
+		{12*time.Hour + 30*time.Minute, "12h 30m"},
+		{12*time.Hour + 29*time.Minute + 40*time.Second, "12h 30m"},
+	}
+
+	for _, tt := range tests {
+		if got := Duration(tt.d).String(); got != tt.want {
+			t.Errorf("Duration(%v).String() = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		{`"12h 30m"`, 12*time.Hour + 30*time.Minute},
+		{`"PT12H30M"`, 12*time.Hour + 30*time.Minute},
+		{`""`, 0},
+		{`750`, 12*time.Hour + 30*time.Minute},
+		// Unknown legacy values are read as unknown, not as an error.
+		{`"about half a day"`, 0},
+	}
+
+	for _, tt := range tests {
+		var book Book
+		if err := json.Unmarshal([]byte(`{"duration": `+tt.json+`}`), &book); err != nil {
+			t.Errorf("decoding duration %s: %v", tt.json, err)
+			continue
+		}
+		if time.Duration(book.Duration) != tt.want {
+			t.Errorf("decoding duration %s = %v, want %v", tt.json, time.Duration(book.Duration), tt.want)
+		}
+	}
+}
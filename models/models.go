@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Books struct {
 	Books []Book `json:"books"`
@@ -18,13 +23,15 @@ type Book struct {
 	Type        []BookType `json:"type"`
 	Description string     `json:"description"`
 	Cover       string     `json:"cover"`
+	CoverSource string     `json:"cover_source,omitempty"` // Where a stored cover was downloaded from
 	Genre       string     `json:"genre"`
 	Tags        []string   `json:"tags"`
 	Link        string     `json:"link"`
 	Status      Status     `json:"status"`
-	Rating      int        `json:"rating"`
+	Rating      float64    `json:"rating"` // Stars out of five, fractions allowed
 	Pages       int        `json:"pages"`
-	Duration    string     `json:"duration"` // For audiobooks
+	Duration    Duration   `json:"duration"` // For audiobooks
+	Narrator    string     `json:"narrator"` // For audiobooks
 	Publisher   string     `json:"publisher"`
 	Published   time.Time  `json:"published"`
 	Added       time.Time  `json:"added"`
@@ -33,6 +40,51 @@ type Book struct {
 	Notes       string     `json:"notes"`
 	Series      string     `json:"series"`
 	SeriesOrder int        `json:"series_order"`
+	Private     bool       `json:"private"`        // Hidden from shared shelves
+	HideNotes   bool       `json:"hide_notes"`     // Notes never shown on shared shelves
+	Loan        *Loan      `json:"loan,omitempty"` // The latest loan, if the book was lent
+}
+
+// Loan records a book lent to someone. Loans are personal, so shared shelves
+// and the other public reads leave them out.
+type Loan struct {
+	To       string    `json:"to"`
+	Lent     time.Time `json:"lent"`
+	Due      time.Time `json:"due"`      // When the book should be back, if agreed
+	Returned time.Time `json:"returned"` // Set once it is back
+}
+
+// Goal is a reading goal: a number of books to finish between Start and
+// Deadline.
+type Goal struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Books    int       `json:"books"`
+	Start    time.Time `json:"start"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// Progress counts the books finished within the goal's dates, including
+// the whole deadline day.
+func (g Goal) Progress(books []Book) int {
+	end := g.Deadline.AddDate(0, 0, 1)
+	n := 0
+	for _, book := range books {
+		if book.Finished.IsZero() || book.Finished.Before(g.Start) || !book.Finished.Before(end) {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+type Goals struct {
+	Goals []Goal `json:"goals"`
+}
+
+type PostGoal struct {
+	Goal Goal   `json:"goal"`
+	Key  string `json:"key"`
 }
 
 type BookType string
@@ -61,6 +113,23 @@ type FeaturedBook struct {
 
 type IndexParams struct {
 	Host string
+	// Root is the path from the page to the site root, ending in "/". It is
+	// "/" on the server and relative, such as "../../", in a static build,
+	// so the build can be hosted under any path.
+	Root   string
+	Static bool      // a static build, which has no API to call
+	Nav    []NavLink // links to related pages, relative to Root
+}
+
+// BookPageParams is the data for a server-rendered book page.
+type BookPageParams struct {
+	IndexParams
+	Book    Book
+	URL     string      // canonical URL of the page
+	Image   string      // absolute cover URL for link previews
+	Summary string      // short plain-text description
+	Stars   []int       // one entry per rating star
+	JSONLD  interface{} // schema.org Book, encoded by the template
 }
 
 type PostBook struct {
@@ -68,6 +137,52 @@ type PostBook struct {
 	Key  string `json:"key"`
 }
 
+// Share is a read-only public view of part of the library, reachable by
+// anyone holding its token.
+type Share struct {
+	Token     string     `json:"token"`
+	Name      string     `json:"name"`
+	Filter    BookFilter `json:"filter"`
+	ShowNotes bool       `json:"show_notes"`
+	Created   time.Time  `json:"created"`
+}
+
+type Shares struct {
+	Shares []Share `json:"shares"`
+}
+
+type PostShare struct {
+	Share Share  `json:"share"`
+	Key   string `json:"key"`
+}
+
+// SharedShelfParams is the data for a shared shelf page.
+type SharedShelfParams struct {
+	IndexParams
+	Share Share
+	Books []Book
+}
+
+type NavLink struct {
+	Title string
+	URL   string
+}
+
+// StatsPageParams is the data for a statistics or year-in-review page.
+type StatsPageParams struct {
+	IndexParams
+	Title    string
+	Stats    BookStats
+	Year     int    // zero for all-time statistics
+	Finished []Book // books finished in Year, oldest first
+	ByMonth  []MonthCount
+}
+
+type MonthCount struct {
+	Month string
+	Count int
+}
+
 type PostFeatured struct {
 	FeaturedBook FeaturedBook `json:"featured_book"`
 	Key          string       `json:"key"`
@@ -91,3 +206,20 @@ type BookStats struct {
 	PagesRead     int            `json:"pages_read"`
 	HoursListened int            `json:"hours_listened"`
 }
+
+// Stars draws a rating as black stars, rounded to the nearest half star,
+// with ½ for the half.
+func Stars(rating float64) string {
+	halves := int(math.Round(rating * 2))
+	stars := strings.Repeat("★", halves/2)
+	if halves%2 == 1 {
+		stars += "½"
+	}
+	return stars
+}
+
+// FormatRating formats a rating without trailing zeros, as in "4" or
+// "3.75".
+func FormatRating(rating float64) string {
+	return strconv.FormatFloat(rating, 'f', -1, 64)
+}
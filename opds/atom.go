@@ -0,0 +1,204 @@
+package opds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	coverstore "github.com/rahutchinson/book-list/coverstore"
+	models "github.com/rahutchinson/book-list/models"
+	web "github.com/rahutchinson/book-list/web"
+)
+
+const (
+	navigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	acquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Dc      string      `xml:"xmlns:dc,attr"`
+	Opds    string      `xml:"xmlns:opds,attr"`
+	Thr     string      `xml:"xmlns:thr,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Count int    `xml:"thr:count,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author,omitempty"`
+	Identifier string         `xml:"dc:identifier,omitempty"`
+	Publisher  string         `xml:"dc:publisher,omitempty"`
+	Issued     string         `xml:"dc:issued,omitempty"`
+	Categories []atomCategory `xml:"category,omitempty"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content,omitempty"`
+	Links      []atomLink     `xml:"link"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func atomType(p page) string {
+	if p.books != nil || p.navigation == nil {
+		return acquisitionType
+	}
+	return navigationType
+}
+
+func writeAtom(w io.Writer, root, title string, p page) error {
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Dc:      "http://purl.org/dc/terms/",
+		Opds:    "http://opds-spec.org/2010/catalog",
+		Thr:     "http://purl.org/syndication/thread/1.0",
+		ID:      "urn:book-list:opds:" + p.id,
+		Title:   p.title,
+		Updated: p.updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: root + "/" + p.path, Type: atomType(p)},
+			{Rel: "start", Href: root + "/", Type: navigationType, Title: title},
+			{Rel: "search", Href: root + "/opensearch.xml", Type: "application/opensearchdescription+xml"},
+		},
+	}
+
+	for _, item := range p.navigation {
+		entry := atomEntry{
+			ID:      "urn:book-list:opds:" + item.path,
+			Title:   item.title,
+			Updated: feed.Updated,
+			Links:   []atomLink{{Rel: "subsection", Href: root + "/" + item.path, Type: navigationType, Count: item.count}},
+		}
+		switch {
+		case item.count == 1:
+			entry.Content = &atomContent{Type: "text", Body: "1 book"}
+		case item.count > 1:
+			entry.Content = &atomContent{Type: "text", Body: fmt.Sprintf("%d books", item.count)}
+		}
+		if item.path == "all" || isGroupItem(item.path) {
+			entry.Links[0].Type = acquisitionType
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	for _, book := range p.books {
+		feed.Entries = append(feed.Entries, atomBook(book, p.updated))
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+// isGroupItem reports whether a navigation path points at the books of a
+// single author, genre, series or status rather than another navigation feed.
+func isGroupItem(path string) bool {
+	for name := range groups {
+		if len(path) > len(name) && path[:len(name)+1] == name+"/" {
+			return true
+		}
+	}
+	return false
+}
+
+func atomBook(book models.Book, fallback time.Time) atomEntry {
+	updated := book.Added
+	if updated.IsZero() {
+		updated = fallback
+	}
+
+	entry := atomEntry{
+		ID:        "urn:book-list:book:" + book.ID,
+		Title:     book.Name,
+		Updated:   updated.UTC().Format(time.RFC3339),
+		Publisher: book.Publisher,
+		Summary:   book.Description,
+	}
+	if book.Author != "" {
+		entry.Authors = []atomPerson{{Name: book.Author}}
+	}
+	if book.ISBN != "" {
+		entry.Identifier = "urn:isbn:" + book.ISBN
+	}
+	if !book.Published.IsZero() {
+		entry.Issued = book.Published.Format("2006-01-02")
+	}
+	if book.Genre != "" {
+		entry.Categories = append(entry.Categories, atomCategory{Term: book.Genre, Label: book.Genre})
+	}
+	for _, tag := range book.Tags {
+		entry.Categories = append(entry.Categories, atomCategory{Term: tag, Label: tag})
+	}
+
+	if book.Cover != "" {
+		thumbnail := coverstore.Thumbnail(book.Cover, "medium")
+		entry.Links = append(entry.Links,
+			atomLink{Rel: "http://opds-spec.org/image", Href: book.Cover, Type: web.ImageType(book.Cover)},
+			atomLink{Rel: "http://opds-spec.org/image/thumbnail", Href: thumbnail, Type: web.ImageType(thumbnail)},
+		)
+	}
+	if book.Link != "" {
+		rel, mediaType := acquisition(book.Link)
+		entry.Links = append(entry.Links, atomLink{Rel: rel, Href: book.Link, Type: mediaType})
+	}
+	entry.Links = append(entry.Links, atomLink{Rel: "alternate", Href: "/book/" + url.PathEscape(book.ID), Type: "text/html"})
+
+	return entry
+}
+
+type openSearchDescription struct {
+	XMLName     xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns       string          `xml:"xmlns,attr"`
+	ShortName   string          `xml:"ShortName"`
+	Description string          `xml:"Description"`
+	URLs        []openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+func writeOpenSearch(w io.Writer, title, root string) error {
+	desc := openSearchDescription{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   title,
+		Description: "Search " + title + " by title, author or description",
+		URLs: []openSearchURL{
+			{Type: acquisitionType, Template: root + "/search?q={searchTerms}"},
+		},
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(desc)
+}
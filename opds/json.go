@@ -0,0 +1,139 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+	web "github.com/rahutchinson/book-list/web"
+)
+
+const jsonType = "application/opds+json"
+
+type jsonFeed struct {
+	Metadata     jsonMetadata      `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified,omitempty"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+}
+
+type jsonLink struct {
+	Rel        string                 `json:"rel,omitempty"`
+	Href       string                 `json:"href"`
+	Type       string                 `json:"type,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Templated  bool                   `json:"templated,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type jsonPublication struct {
+	Metadata jsonBookMetadata `json:"metadata"`
+	Links    []jsonLink       `json:"links"`
+	Images   []jsonLink       `json:"images,omitempty"`
+}
+
+type jsonBookMetadata struct {
+	Type        string         `json:"@type"`
+	Title       string         `json:"title"`
+	Identifier  string         `json:"identifier,omitempty"`
+	Author      string         `json:"author,omitempty"`
+	Publisher   string         `json:"publisher,omitempty"`
+	Published   string         `json:"published,omitempty"`
+	Modified    string         `json:"modified,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Subject     []string       `json:"subject,omitempty"`
+	BelongsTo   *jsonBelongsTo `json:"belongsTo,omitempty"`
+}
+
+type jsonBelongsTo struct {
+	Series []jsonSeries `json:"series"`
+}
+
+type jsonSeries struct {
+	Name     string `json:"name"`
+	Position int    `json:"position,omitempty"`
+}
+
+func writeJSON(w io.Writer, root string, p page) error {
+	feed := jsonFeed{
+		Metadata: jsonMetadata{Title: p.title, Modified: p.updated.Format(time.RFC3339)},
+		Links: []jsonLink{
+			{Rel: "self", Href: root + "/" + p.path, Type: jsonType},
+			{Rel: "start", Href: root + "/", Type: jsonType},
+			{Rel: "search", Href: root + "/search{?q}", Type: jsonType, Templated: true},
+		},
+	}
+
+	for _, item := range p.navigation {
+		link := jsonLink{Rel: "subsection", Href: root + "/" + item.path, Type: jsonType, Title: item.title}
+		if item.count > 0 {
+			link.Properties = map[string]interface{}{"numberOfItems": item.count}
+		}
+		feed.Navigation = append(feed.Navigation, link)
+	}
+
+	if p.navigation == nil {
+		feed.Metadata.NumberOfItems = len(p.books)
+		// OPDS 2.0 requires the collection to be present even when empty.
+		feed.Publications = []jsonPublication{}
+	}
+	for _, book := range p.books {
+		feed.Publications = append(feed.Publications, jsonBook(book))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+func jsonBook(book models.Book) jsonPublication {
+	pub := jsonPublication{
+		Metadata: jsonBookMetadata{
+			Type:        "http://schema.org/Book",
+			Title:       book.Name,
+			Author:      book.Author,
+			Publisher:   book.Publisher,
+			Description: book.Description,
+		},
+	}
+	if book.ISBN != "" {
+		pub.Metadata.Identifier = "urn:isbn:" + book.ISBN
+	} else {
+		pub.Metadata.Identifier = "urn:book-list:book:" + book.ID
+	}
+	if !book.Published.IsZero() {
+		pub.Metadata.Published = book.Published.Format("2006-01-02")
+	}
+	if !book.Added.IsZero() {
+		pub.Metadata.Modified = book.Added.UTC().Format(time.RFC3339)
+	}
+	if book.Genre != "" {
+		pub.Metadata.Subject = append(pub.Metadata.Subject, book.Genre)
+	}
+	pub.Metadata.Subject = append(pub.Metadata.Subject, book.Tags...)
+	if book.Series != "" {
+		pub.Metadata.BelongsTo = &jsonBelongsTo{Series: []jsonSeries{{Name: book.Series, Position: book.SeriesOrder}}}
+	}
+
+	if book.Link != "" {
+		rel, mediaType := acquisition(book.Link)
+		pub.Links = append(pub.Links, jsonLink{Rel: rel, Href: book.Link, Type: mediaType})
+	} else {
+		// Publications must carry at least one link.
+		pub.Links = append(pub.Links, jsonLink{Rel: "alternate", Href: "/book/" + url.PathEscape(book.ID), Type: "text/html"})
+	}
+
+	if book.Cover != "" {
+		pub.Images = append(pub.Images, jsonLink{Href: book.Cover, Type: web.ImageType(book.Cover)})
+	}
+
+	return pub
+}
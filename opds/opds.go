@@ -0,0 +1,232 @@
+// Package opds serves the library as an OPDS catalog so e-reader apps can
+// browse it. Both OPDS 1.2 (Atom XML) and OPDS 2.0 (JSON) are supported.
+package opds
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+	web "github.com/rahutchinson/book-list/web"
+)
+
+// Handler serves a catalog rooted at Prefix. It answers:
+//
+//	{Prefix}/                         root navigation feed
+//	{Prefix}/all                      every book
+//	{Prefix}/{group}                  navigation by author, genre, series or status
+//	{Prefix}/{group}/{name}           books in one group
+//	{Prefix}/search?q=...             search results
+//	{Prefix}/opensearch.xml           OpenSearch description
+type Handler struct {
+	Prefix string
+	Title  string
+	// JSON selects OPDS 2.0 instead of OPDS 1.2.
+	JSON   bool
+	Books  func() []models.Book
+	Search func(books []models.Book, query string) []models.Book
+}
+
+// page is a single catalog feed, either navigation or acquisition.
+type page struct {
+	id         string
+	title      string
+	path       string
+	updated    time.Time
+	navigation []navItem
+	books      []models.Book
+}
+
+type navItem struct {
+	title string
+	path  string
+	count int
+}
+
+// group describes one of the navigation facets.
+type group struct {
+	title string
+	keys  func(models.Book) []string
+	label func(string) string
+}
+
+var groups = map[string]group{
+	"authors": {
+		title: "By Author",
+		keys:  func(b models.Book) []string { return []string{b.Author} },
+	},
+	"genres": {
+		title: "By Genre",
+		keys:  func(b models.Book) []string { return []string{b.Genre} },
+	},
+	"series": {
+		title: "By Series",
+		keys:  func(b models.Book) []string { return []string{b.Series} },
+	},
+	"status": {
+		title: "By Status",
+		keys:  func(b models.Book) []string { return []string{string(b.Status)} },
+		label: statusLabel,
+	},
+}
+
+var groupOrder = []string{"authors", "genres", "series", "status"}
+
+func statusLabel(s string) string {
+	switch models.Status(s) {
+	case models.Unread:
+		return "Unread"
+	case models.Reading:
+		return "Currently Reading"
+	case models.Completed:
+		return "Completed"
+	case models.Abandoned:
+		return "Abandoned"
+	case models.WantToRead:
+		return "Want to Read"
+	}
+	return s
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base := web.BaseURL(req)
+	rest := strings.Trim(strings.TrimPrefix(req.URL.EscapedPath(), h.Prefix), "/")
+
+	if rest == "opensearch.xml" {
+		w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+		writeOpenSearch(w, h.Title, base+h.Prefix)
+		return
+	}
+
+	p, ok := h.page(rest, req.URL.Query().Get("q"))
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	if h.JSON {
+		w.Header().Set("Content-Type", jsonType)
+		writeJSON(w, base+h.Prefix, p)
+		return
+	}
+	w.Header().Set("Content-Type", atomType(p))
+	writeAtom(w, base+h.Prefix, h.Title, p)
+}
+
+// page builds the feed for a path relative to the catalog root.
+func (h Handler) page(rest, query string) (page, bool) {
+	books := h.Books()
+	updated := lastUpdated(books)
+
+	if rest == "" {
+		p := page{id: "root", title: h.Title, updated: updated}
+		p.navigation = append(p.navigation, navItem{title: "All Books", path: "all", count: len(books)})
+		for _, name := range groupOrder {
+			p.navigation = append(p.navigation, navItem{title: groups[name].title, path: name})
+		}
+		return p, true
+	}
+
+	if rest == "all" {
+		return page{id: "all", title: "All Books", path: rest, updated: updated, books: books}, true
+	}
+
+	if rest == "search" {
+		results := h.Search(books, query)
+		return page{id: "search", title: "Search: " + query, path: rest + "?q=" + url.QueryEscape(query), updated: updated, books: results}, true
+	}
+
+	parts := strings.SplitN(rest, "/", 2)
+	g, ok := groups[parts[0]]
+	if !ok {
+		return page{}, false
+	}
+
+	if len(parts) == 1 {
+		p := page{id: parts[0], title: g.title, path: rest, updated: updated}
+		counts := make(map[string]int)
+		for _, book := range books {
+			for _, key := range g.keys(book) {
+				if key != "" {
+					counts[key]++
+				}
+			}
+		}
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			title := name
+			if g.label != nil {
+				title = g.label(name)
+			}
+			p.navigation = append(p.navigation, navItem{title: title, path: parts[0] + "/" + url.PathEscape(name), count: counts[name]})
+		}
+		return p, true
+	}
+
+	name, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return page{}, false
+	}
+	var matched []models.Book
+	for _, book := range books {
+		for _, key := range g.keys(book) {
+			if key == name {
+				matched = append(matched, book)
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return page{}, false
+	}
+	if parts[0] == "series" {
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].SeriesOrder < matched[j].SeriesOrder })
+	}
+
+	title := name
+	if g.label != nil {
+		title = g.label(name)
+	}
+	return page{id: parts[0] + ":" + name, title: title, path: rest, updated: lastUpdated(matched), books: matched}, true
+}
+
+func lastUpdated(books []models.Book) time.Time {
+	var latest time.Time
+	for _, book := range books {
+		for _, t := range []time.Time{book.Added, book.Started, book.Finished} {
+			if t.After(latest) {
+				latest = t
+			}
+		}
+	}
+	if latest.IsZero() {
+		latest = time.Now()
+	}
+	return latest.UTC()
+}
+
+// acquisition describes how a book's Link should be advertised: direct
+// downloads of ebook files are plain acquisitions, anything else is treated
+// as a store page.
+func acquisition(link string) (rel, mediaType string) {
+	lower := strings.ToLower(link)
+	switch {
+	case strings.HasSuffix(lower, ".epub"):
+		return "http://opds-spec.org/acquisition", "application/epub+zip"
+	case strings.HasSuffix(lower, ".pdf"):
+		return "http://opds-spec.org/acquisition", "application/pdf"
+	}
+	return "http://opds-spec.org/acquisition/buy", "text/html"
+}
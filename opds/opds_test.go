@@ -0,0 +1,89 @@
+package opds
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+var catalog = []models.Book{
+	{
+		ID: "1", Name: "Dune & <Messiah>", Author: "Frank Herbert", ISBN: "9780441013593", Status: models.Completed,
+		Genre: "Science Fiction", Tags: []string{"classic"}, Publisher: "Ace", Description: "Sand & worms",
+		Published: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC), Added: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Series: "Dune", SeriesOrder: 2, Cover: "/covers/dune.jpg", Link: "https://books.example.com/dune.epub",
+	},
+	{
+		ID: "2", Name: "Émile", Author: "Rousseau", Status: models.Reading,
+		Added: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC),
+	},
+}
+
+func TestCatalog(t *testing.T) {
+	search := func(books []models.Book, query string) []models.Book {
+		var found []models.Book
+		for _, book := range books {
+			if strings.Contains(strings.ToLower(book.Name), strings.ToLower(query)) {
+				found = append(found, book)
+			}
+		}
+		return found
+	}
+	books := func() []models.Book { return catalog }
+	atom := Handler{Prefix: "/opds", Title: "Shelf & Co", Books: books, Search: search}
+	json := Handler{Prefix: "/opds2", Title: "Shelf & Co", JSON: true, Books: books, Search: search}
+
+	tests := []struct {
+		handler Handler
+		target  string
+		golden  string
+	}{
+		{handler: atom, target: "/opds/", golden: "root.xml"},
+		{handler: atom, target: "/opds/all", golden: "all.xml"},
+		{handler: atom, target: "/opds/authors", golden: "authors.xml"},
+		{handler: atom, target: "/opds/search?q=dune", golden: "search.xml"},
+		{handler: atom, target: "/opds/opensearch.xml", golden: "opensearch.xml"},
+		{handler: json, target: "/opds2/", golden: "root.json"},
+		{handler: json, target: "/opds2/all", golden: "all.json"},
+		{handler: json, target: "/opds2/search?q=nothing", golden: "search.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://books.example.com"+tt.target, nil))
+			if rec.Code != 200 {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			golden(t, tt.golden, rec.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// Package opf reads the Open Packaging Format metadata used by EPUB package
+// documents and Calibre's metadata.opf files.
+package opf
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	isbn "github.com/rahutchinson/book-list/isbn"
+	models "github.com/rahutchinson/book-list/models"
+	parse "github.com/rahutchinson/book-list/parse"
+)
+
+// Package is the subset of an OPF package document the bookshelf uses.
+type Package struct {
+	Version  string   `xml:"version,attr"`
+	Metadata Metadata `xml:"metadata"`
+	Manifest []Item   `xml:"manifest>item"`
+	Guide    []Ref    `xml:"guide>reference"`
+}
+
+// Metadata holds the Dublin Core elements and <meta> extensions.
+type Metadata struct {
+	Titles      []string     `xml:"title"`
+	Creators    []Creator    `xml:"creator"`
+	Languages   []string     `xml:"language"`
+	Publisher   string       `xml:"publisher"`
+	Date        string       `xml:"date"`
+	Description string       `xml:"description"`
+	Subjects    []string     `xml:"subject"`
+	Identifiers []Identifier `xml:"identifier"`
+	Meta        []Meta       `xml:"meta"`
+}
+
+// Creator is a dc:creator. Role is "aut" for authors; EPUB 3 moves the role
+// into a refining <meta> element instead.
+type Creator struct {
+	ID     string `xml:"id,attr"`
+	Role   string `xml:"role,attr"`
+	FileAs string `xml:"file-as,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// Identifier is a dc:identifier such as an ISBN, UUID or Calibre ID.
+type Identifier struct {
+	ID     string `xml:"id,attr" json:"id,omitempty"`
+	Scheme string `xml:"scheme,attr" json:"scheme,omitempty"`
+	Value  string `xml:",chardata" json:"value"`
+}
+
+// Meta is either an EPUB 2 name/content pair or an EPUB 3 property element.
+type Meta struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Item is a manifest entry.
+type Item struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// Ref is an EPUB 2 guide reference.
+type Ref struct {
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// Parse decodes an OPF package document.
+func Parse(r io.Reader) (*Package, error) {
+	var p Package
+	if err := xml.NewDecoder(r).Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// meta returns the value of a named meta element, checking both the EPUB 2
+// name/content form and the EPUB 3 property form.
+func (p *Package) meta(name string) string {
+	for _, m := range p.Metadata.Meta {
+		if m.Refines != "" {
+			continue
+		}
+		if m.Name == name {
+			return strings.TrimSpace(m.Content)
+		}
+		if m.Property == name {
+			return strings.TrimSpace(m.Value)
+		}
+	}
+	return ""
+}
+
+// refinement returns the value of an EPUB 3 meta that refines the element
+// with the given id.
+func (p *Package) refinement(id, property string) string {
+	for _, m := range p.Metadata.Meta {
+		if m.Refines == "#"+id && m.Property == property {
+			return strings.TrimSpace(m.Value)
+		}
+	}
+	return ""
+}
+
+// Authors returns the creators with an author role, or every creator when
+// none carry a role.
+func (p *Package) Authors() []string {
+	var authors, all []string
+	for _, c := range p.Metadata.Creators {
+		name := strings.TrimSpace(c.Name)
+		if name == "" {
+			continue
+		}
+		all = append(all, name)
+		role := c.Role
+		if role == "" && c.ID != "" {
+			role = p.refinement(c.ID, "role")
+		}
+		if role == "aut" {
+			authors = append(authors, name)
+		}
+	}
+	if len(authors) == 0 {
+		return all
+	}
+	return authors
+}
+
+// ISBN returns the first identifier that is marked or shaped like an ISBN.
+func (p *Package) ISBN() string {
+	for _, id := range p.Metadata.Identifiers {
+		value := strings.TrimSpace(id.Value)
+		lower := strings.ToLower(value)
+		switch {
+		case strings.EqualFold(id.Scheme, "isbn"):
+			return isbn.Normalize(value)
+		case strings.HasPrefix(lower, "urn:isbn:"):
+			return isbn.Normalize(value[len("urn:isbn:"):])
+		case strings.HasPrefix(lower, "isbn:"):
+			return isbn.Normalize(value[len("isbn:"):])
+		}
+	}
+	return ""
+}
+
+// Series returns the series name and position from Calibre's meta elements
+// or the EPUB 3 belongs-to-collection property.
+func (p *Package) Series() (string, int) {
+	if name := p.meta("calibre:series"); name != "" {
+		return name, seriesIndex(p.meta("calibre:series_index"))
+	}
+	for _, m := range p.Metadata.Meta {
+		if m.Property == "belongs-to-collection" && m.Refines == "" {
+			name := strings.TrimSpace(m.Value)
+			return name, seriesIndex(p.refinement(m.ID, "group-position"))
+		}
+	}
+	return "", 0
+}
+
+// CoverHref returns the manifest path of the cover image, if any.
+func (p *Package) CoverHref() string {
+	for _, item := range p.Manifest {
+		if strings.Contains(item.Properties, "cover-image") {
+			return item.Href
+		}
+	}
+	if id := p.meta("cover"); id != "" {
+		for _, item := range p.Manifest {
+			if item.ID == id {
+				return item.Href
+			}
+		}
+	}
+	for _, ref := range p.Guide {
+		if ref.Type == "cover" && isImage(ref.Href) {
+			return ref.Href
+		}
+	}
+	return ""
+}
+
+// Book converts the package metadata into a book. The cover is left for the
+// caller since its location depends on where the package came from.
+func (p *Package) Book() models.Book {
+	book := models.Book{
+		ISBN:        p.ISBN(),
+		Publisher:   strings.TrimSpace(p.Metadata.Publisher),
+		Description: stripTags(p.Metadata.Description),
+		Published:   parseDate(p.Metadata.Date),
+	}
+	if len(p.Metadata.Titles) > 0 {
+		book.Name = strings.TrimSpace(p.Metadata.Titles[0])
+	}
+	book.Author = strings.Join(p.Authors(), ", ")
+	book.Series, book.SeriesOrder = p.Series()
+
+	for _, subject := range p.Metadata.Subjects {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			book.Tags = append(book.Tags, subject)
+		}
+	}
+	if len(book.Tags) > 0 {
+		book.Genre = book.Tags[0]
+	}
+
+	// Calibre stores ratings out of 10.
+	if rating, err := strconv.ParseFloat(p.meta("calibre:rating"), 64); err == nil && rating > 0 {
+		book.Rating = rating / 2
+	}
+
+	return book
+}
+
+func seriesIndex(value string) int {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int(f)
+}
+
+// parseDate accepts the date forms OPF allows, from a bare year up to a full
+// timestamp. Calibre writes 0101-01-01 for unknown dates.
+func parseDate(value string) time.Time {
+	t := parse.Date(value, time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006")
+	if t.Year() <= 101 {
+		return time.Time{}
+	}
+	return t
+}
+
+func isImage(href string) bool {
+	lower := strings.ToLower(href)
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif", ".webp"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTags removes the HTML markup Calibre and publishers put in
+// descriptions, and decodes the entities left behind.
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(html.UnescapeString(b.String()))
+}
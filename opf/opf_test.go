@@ -0,0 +1,58 @@
+package opf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+func TestBook(t *testing.T) {
+	tests := []struct {
+		file  string
+		want  models.Book
+		cover string
+	}{
+		{
+			file: "epub3.opf",
+			want: models.Book{
+				Name: "Dune & Other Stories", Author: "Frank Herbert", ISBN: "9780441013593",
+				Publisher: "Ace", Published: time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC),
+				Description: "Sand & worms", Genre: "Science Fiction", Tags: []string{"Science Fiction", "Classics"},
+				Series: "Dune Chronicles", SeriesOrder: 1,
+			},
+			cover: "images/cover%20art.png",
+		},
+		{
+			file: "calibre.opf",
+			want: models.Book{
+				Name: "Emma", Author: "Jane Austen", ISBN: "9780141439587",
+				Series: "Austen Novels", SeriesOrder: 4, Rating: 4,
+			},
+			cover: "cover.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			pkg, err := Parse(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pkg.Book(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Book = %+v, want %+v", got, tt.want)
+			}
+			if got := pkg.CoverHref(); got != tt.cover {
+				t.Errorf("CoverHref = %q, want %q", got, tt.cover)
+			}
+		})
+	}
+}
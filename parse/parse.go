@@ -0,0 +1,35 @@
+// Package parse holds the lenient field parsing shared by the importers,
+// the exporters and the metadata lookups, where a value that does not parse
+// is treated as missing rather than as an error.
+package parse
+
+import (
+	"strings"
+	"time"
+)
+
+// List splits a delimited list, trimming and dropping empty entries.
+func List(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Date tries each layout in turn and returns the zero time for empty or
+// unparseable values.
+func Date(value string, layouts ...string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
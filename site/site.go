@@ -0,0 +1,406 @@
+// Package site renders the library into a static website that can be hosted
+// without running the server.
+package site
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	coverstore "github.com/rahutchinson/book-list/coverstore"
+	feed "github.com/rahutchinson/book-list/feed"
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// Site describes a static build. The templates are the same ones the server
+// renders: IndexPage is index.html, which a static build renders read-only
+// over books.json and stats.json. BookParams and Stats are the server's
+// helpers for filling the others.
+//
+// Every link in the output is relative, so the site works wherever it is
+// hosted, including under a path such as https://user.github.io/books/.
+type Site struct {
+	Dir     string // output directory
+	BaseURL string // public root, e.g. https://books.example.com
+	Title   string
+
+	IndexPage *template.Template
+	BookPage  *template.Template
+	ShelfPage *template.Template
+	StatsPage *template.Template
+
+	BookParams func(models.Book) models.BookPageParams
+	Stats      func([]models.Book) models.BookStats
+
+	// Covers holds the stored covers. Only those of the books being built
+	// are copied into the site, so covers of private books stay private.
+	Covers *coverstore.Store
+
+	// Assets maps output subdirectories to source directories copied into
+	// the site as-is, such as the scripts.
+	Assets map[string]string
+}
+
+// Build writes the whole site: the index, a page per book, author and
+// series, statistics, a year in review per year with finished books,
+// placeholder covers for books without one, and books.json, stats.json and
+// feed.xml.
+func (s Site) Build(books []models.Book) error {
+	for _, book := range books {
+		if !validID(book.ID) {
+			return fmt.Errorf("book ID %q cannot be used as a path", book.ID)
+		}
+	}
+
+	host := strings.TrimPrefix(strings.TrimPrefix(s.BaseURL, "https://"), "http://")
+	byAuthor := group(books, func(b models.Book) string { return b.Author })
+	bySeries := group(books, func(b models.Book) string { return b.Series })
+	authorSlugs := uniqueSlugs(sortedKeys(byAuthor))
+	seriesSlugs := uniqueSlugs(sortedKeys(bySeries))
+	years := finishedYears(books)
+
+	nav := []models.NavLink{{Title: "All Books", URL: ""}, {Title: "Statistics", URL: "stats/"}}
+	for _, year := range years {
+		nav = append(nav, models.NavLink{Title: fmt.Sprint(year), URL: fmt.Sprintf("year/%d/", year)})
+	}
+	for _, name := range sortedKeys(bySeries) {
+		nav = append(nav, models.NavLink{Title: name, URL: "series/" + seriesSlugs[name] + "/"})
+	}
+	for _, name := range sortedKeys(byAuthor) {
+		nav = append(nav, models.NavLink{Title: name, URL: "author/" + authorSlugs[name] + "/"})
+	}
+	page := func(root string) models.IndexParams {
+		return models.IndexParams{Host: host, Root: root, Static: true, Nav: nav}
+	}
+
+	display, preview, err := s.covers(books)
+	if err != nil {
+		return err
+	}
+
+	if err := s.render(s.IndexPage, "index.html", page("./")); err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		// Link previews need an absolute URL, which BookParams builds from
+		// a cover relative to the site root.
+		b := book
+		b.Cover = preview[book.ID]
+		params := s.BookParams(b)
+		params.IndexParams = page("../../")
+		params.Book.Cover = relative("../../", display[book.ID])
+		if err := s.render(s.BookPage, filepath.Join("book", book.ID, "index.html"), params); err != nil {
+			return err
+		}
+	}
+
+	for name, list := range byAuthor {
+		params := models.SharedShelfParams{IndexParams: page("../../"), Share: models.Share{Name: name}, Books: withCovers(list, "../../", display)}
+		if err := s.render(s.ShelfPage, filepath.Join("author", authorSlugs[name], "index.html"), params); err != nil {
+			return err
+		}
+	}
+
+	for name, list := range bySeries {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].SeriesOrder < list[j].SeriesOrder })
+		params := models.SharedShelfParams{IndexParams: page("../../"), Share: models.Share{Name: name}, Books: withCovers(list, "../../", display)}
+		if err := s.render(s.ShelfPage, filepath.Join("series", seriesSlugs[name], "index.html"), params); err != nil {
+			return err
+		}
+	}
+
+	stats := s.Stats(books)
+	if err := s.render(s.StatsPage, filepath.Join("stats", "index.html"), models.StatsPageParams{IndexParams: page("../"), Title: "Statistics", Stats: stats}); err != nil {
+		return err
+	}
+
+	for _, year := range years {
+		if err := s.render(s.StatsPage, filepath.Join("year", fmt.Sprint(year), "index.html"), s.yearInReview(page("../../"), books, year)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeJSON("books.json", models.Books{Books: withCovers(books, "", display)}); err != nil {
+		return err
+	}
+	if err := s.writeJSON("stats.json", stats); err != nil {
+		return err
+	}
+
+	if err := s.write("feed.xml", func(w io.Writer) error {
+		return feed.WriteRSS(w, s.finishedFeed(withCovers(books, "", preview)))
+	}); err != nil {
+		return err
+	}
+
+	for dest, src := range s.Assets {
+		if err := copyDir(src, filepath.Join(s.Dir, dest)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// covers returns, for every book ID, the cover to show on pages and the one
+// to use in link previews and feeds, as links relative to the site root or
+// remote URLs. Stored covers are copied into covers/ with their thumbnails.
+// Books without a cover get a placeholder rendered into covers/placeholder/,
+// as SVG for the pages and PNG for previews, which do not take SVG.
+func (s Site) covers(books []models.Book) (display, preview map[string]string, err error) {
+	display = make(map[string]string, len(books))
+	preview = make(map[string]string, len(books))
+	for _, book := range books {
+		switch {
+		case book.Cover == "" || coverstore.IsPlaceholder(book.Cover):
+			p := coverstore.Placeholder{Title: book.Name, Author: book.Author, Genre: book.Genre}
+			name := path.Join("covers", "placeholder", book.ID)
+			if err := s.write(name+".svg", func(w io.Writer) error {
+				_, err := w.Write(p.SVG())
+				return err
+			}); err != nil {
+				return nil, nil, err
+			}
+			data, err := p.PNG()
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := s.write(name+".png", func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}); err != nil {
+				return nil, nil, err
+			}
+			display[book.ID], preview[book.ID] = name+".svg", name+".png"
+		case coverstore.IsLocal(book.Cover):
+			if s.Covers != nil {
+				for _, file := range s.Covers.Files(book.Cover) {
+					if err := copyFile(file, filepath.Join(s.Dir, "covers", filepath.Base(file))); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+			display[book.ID] = strings.TrimPrefix(book.Cover, "/")
+			preview[book.ID] = display[book.ID]
+		default:
+			display[book.ID], preview[book.ID] = book.Cover, book.Cover
+		}
+	}
+	return display, preview, nil
+}
+
+// withCovers returns copies of books with the covers from covers, linked
+// from a page root away from the site root.
+func withCovers(books []models.Book, root string, covers map[string]string) []models.Book {
+	linked := make([]models.Book, len(books))
+	for i, book := range books {
+		book.Cover = relative(root, covers[book.ID])
+		linked[i] = book
+	}
+	return linked
+}
+
+// relative prefixes a link relative to the site root with root. Remote URLs
+// are returned unchanged.
+func relative(root, link string) string {
+	if link == "" || strings.Contains(link, "://") {
+		return link
+	}
+	return root + link
+}
+
+// validID reports whether a book ID can name the directory of its page.
+func validID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\?#%`)
+}
+
+// copyDir copies the regular files below src into dest. A missing src is
+// not an error.
+func copyDir(src, dest string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyFile(path, filepath.Join(dest, rel))
+	})
+}
+
+// copyFile copies the file at src to dest, creating its directory.
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+func (s Site) yearInReview(index models.IndexParams, books []models.Book, year int) models.StatsPageParams {
+	var finished []models.Book
+	for _, book := range books {
+		if !book.Finished.IsZero() && book.Finished.Year() == year {
+			finished = append(finished, book)
+		}
+	}
+	sort.SliceStable(finished, func(i, j int) bool { return finished[i].Finished.Before(finished[j].Finished) })
+
+	params := models.StatsPageParams{
+		IndexParams: index,
+		Title:       fmt.Sprintf("%d in Review", year),
+		Stats:       s.Stats(finished),
+		Year:        year,
+		Finished:    finished,
+	}
+	for m := time.January; m <= time.December; m++ {
+		count := 0
+		for _, book := range finished {
+			if book.Finished.Month() == m {
+				count++
+			}
+		}
+		params.ByMonth = append(params.ByMonth, models.MonthCount{Month: m.String(), Count: count})
+	}
+	return params
+}
+
+// finishedFeed lists the most recently finished books.
+func (s Site) finishedFeed(books []models.Book) feed.Feed {
+	f := feed.Feed{
+		Title:       s.Title + ": Recently Finished",
+		Link:        s.BaseURL + "/",
+		Self:        s.BaseURL + "/feed.xml",
+		Description: "Books recently finished on " + s.Title,
+		Updated:     time.Now(),
+	}
+	for _, book := range feed.RecentlyFinished(books, 50) {
+		f.Items = append(f.Items, feed.BookItem(book, s.BaseURL, book.Finished, false))
+	}
+	return f
+}
+
+func (s Site) render(t *template.Template, name string, data interface{}) error {
+	if t == nil {
+		return fmt.Errorf("template for %s is not loaded", name)
+	}
+	return s.write(name, func(w io.Writer) error {
+		return t.Execute(w, data)
+	})
+}
+
+func (s Site) writeJSON(name string, v interface{}) error {
+	return s.write(name, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+}
+
+func (s Site) write(name string, fn func(io.Writer) error) error {
+	path := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return f.Close()
+}
+
+func group(books []models.Book, key func(models.Book) string) map[string][]models.Book {
+	groups := make(map[string][]models.Book)
+	for _, book := range books {
+		if k := key(book); k != "" {
+			groups[k] = append(groups[k], book)
+		}
+	}
+	return groups
+}
+
+func sortedKeys(m map[string][]models.Book) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// finishedYears returns the years in which books were finished, newest
+// first.
+func finishedYears(books []models.Book) []int {
+	seen := make(map[int]bool)
+	var years []int
+	for _, book := range books {
+		if book.Finished.IsZero() {
+			continue
+		}
+		if y := book.Finished.Year(); !seen[y] {
+			seen[y] = true
+			years = append(years, y)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return years
+}
+
+// uniqueSlugs gives each name a slug no other name has, numbering names
+// whose slugs collide in the order given, so "Anne Rice" and "Anne-Rice"
+// get anne-rice and anne-rice-2 rather than one overwriting the other's
+// page.
+func uniqueSlugs(names []string) map[string]string {
+	slugs := make(map[string]string, len(names))
+	used := make(map[string]bool, len(names))
+	for _, name := range names {
+		base := Slug(name)
+		slug := base
+		for n := 2; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[slug] = true
+		slugs[name] = slug
+	}
+	return slugs
+}
+
+// Slug turns a name into a lowercase, hyphenated path segment.
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		// Names without any ASCII letters or digits still need a unique path.
+		return hex.EncodeToString([]byte(name))
+	}
+	return slug
+}
@@ -0,0 +1,83 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	coverstore "github.com/rahutchinson/book-list/coverstore"
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// cover returns a distinct PNG image for each shade.
+func cover(t *testing.T, shade uint8) []byte {
+	img := image.NewGray(image.Rect(0, 0, 40, 60))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildCopiesOnlyItsCovers(t *testing.T) {
+	store := coverstore.NewStore(t.TempDir())
+	shown, err := store.Put(cover(t, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := store.Put(cover(t, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := template.Must(template.New("page").Parse("page"))
+	s := Site{
+		Dir:        t.TempDir(),
+		BaseURL:    "https://books.example.com",
+		Title:      "Shelf",
+		IndexPage:  page,
+		BookPage:   page,
+		ShelfPage:  page,
+		StatsPage:  page,
+		BookParams: func(book models.Book) models.BookPageParams { return models.BookPageParams{Book: book} },
+		Stats:      func([]models.Book) models.BookStats { return models.BookStats{} },
+		Covers:     store,
+	}
+	books := []models.Book{{ID: "1", Name: "Dune", Author: "Frank Herbert", Cover: shown}}
+	if err := s.Build(books); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	entries, _ := os.ReadDir(filepath.Join(s.Dir, "covers"))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			got = append(got, entry.Name())
+		}
+	}
+	var want []string
+	for _, file := range store.Files(shown) {
+		want = append(want, filepath.Base(file))
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(want) < 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("covers = %v, want the cover and thumbnails %v", got, want)
+	}
+	for _, file := range store.Files(hidden) {
+		if _, err := os.Stat(filepath.Join(s.Dir, "covers", filepath.Base(file))); err == nil {
+			t.Errorf("%s of a book not in the site was copied", filepath.Base(file))
+		}
+	}
+}
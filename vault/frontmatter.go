@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+// property is one top-level front matter entry. Generated properties are
+// rendered from Value; properties kept from an existing note carry their
+// original text in raw.
+type property struct {
+	Key   string
+	Value string // YAML-encoded value, empty for null
+	List  []string
+	raw   string
+}
+
+func stringProperty(key, value string) property {
+	return property{Key: key, Value: strconv.Quote(value)}
+}
+
+func intProperty(key string, n int) property {
+	return property{Key: key, Value: strconv.Itoa(n)}
+}
+
+func floatProperty(key string, f float64) property {
+	return property{Key: key, Value: models.FormatRating(f)}
+}
+
+func boolProperty(key string, b bool) property {
+	return property{Key: key, Value: strconv.FormatBool(b)}
+}
+
+// dateProperty is null for unset dates.
+func dateProperty(key string, t time.Time) property {
+	if t.IsZero() {
+		return property{Key: key}
+	}
+	return property{Key: key, Value: t.Format("2006-01-02")}
+}
+
+func listProperty(key string, values []string) property {
+	list := make([]string, len(values))
+	for i, v := range values {
+		list[i] = strconv.Quote(v)
+	}
+	return property{Key: key, List: list}
+}
+
+// bookProperties lists every field of the book model except the notes, which
+// make up the note's body.
+func bookProperties(book models.Book) []property {
+	types := make([]string, len(book.Type))
+	for i, t := range book.Type {
+		types[i] = string(t)
+	}
+	var loan models.Loan
+	if book.Loan != nil {
+		loan = *book.Loan
+	}
+
+	return []property{
+		stringProperty("id", book.ID),
+		stringProperty("isbn", book.ISBN),
+		stringProperty("name", book.Name),
+		stringProperty("author", book.Author),
+		listProperty("type", types),
+		stringProperty("description", book.Description),
+		stringProperty("cover", book.Cover),
+		stringProperty("genre", book.Genre),
+		listProperty("tags", book.Tags),
+		stringProperty("link", book.Link),
+		stringProperty("status", string(book.Status)),
+		floatProperty("rating", book.Rating),
+		intProperty("pages", book.Pages),
+		stringProperty("duration", book.Duration.String()),
+		stringProperty("narrator", book.Narrator),
+		stringProperty("publisher", book.Publisher),
+		dateProperty("published", book.Published),
+		dateProperty("added", book.Added),
+		dateProperty("started", book.Started),
+		dateProperty("finished", book.Finished),
+		stringProperty("series", book.Series),
+		intProperty("series_order", book.SeriesOrder),
+		boolProperty("private", book.Private),
+		boolProperty("hide_notes", book.HideNotes),
+		stringProperty("lent_to", loan.To),
+		dateProperty("lent", loan.Lent),
+		dateProperty("due", loan.Due),
+		dateProperty("returned", loan.Returned),
+	}
+}
+
+// writeFrontMatter writes props as a YAML front matter block. Strings are
+// double-quoted, which YAML reads with the same escapes as Go.
+func writeFrontMatter(b *strings.Builder, props []property) {
+	b.WriteString("---\n")
+	for _, p := range props {
+		switch {
+		case p.raw != "":
+			b.WriteString(p.raw + "\n")
+		case p.List != nil:
+			if len(p.List) == 0 {
+				b.WriteString(p.Key + ": []\n")
+				continue
+			}
+			b.WriteString(p.Key + ":\n")
+			for _, v := range p.List {
+				b.WriteString("  - " + v + "\n")
+			}
+		case p.Value == "":
+			b.WriteString(p.Key + ":\n")
+		default:
+			b.WriteString(p.Key + ": " + p.Value + "\n")
+		}
+	}
+	b.WriteString("---\n")
+}
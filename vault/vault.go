@@ -0,0 +1,369 @@
+// Package vault writes the library as a folder of Markdown notes that
+// Obsidian and similar tools can open as a vault.
+//
+// Every book gets a note under Books/ with YAML front matter holding all of
+// its fields, and every author and series gets an index note under Authors/
+// and Series/. Notes wiki-link to each other. The generated part of a note
+// sits between BeginMarker and EndMarker; anything outside the markers, and
+// any front matter property the library does not define, belongs to the user
+// and is kept when the vault is written incrementally.
+package vault
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	models "github.com/rahutchinson/book-list/models"
+)
+
+const (
+	BeginMarker = "<!-- book-list:begin -->"
+	EndMarker   = "<!-- book-list:end -->"
+)
+
+// Vault is the output of an export.
+type Vault struct {
+	Dir string
+	// Incremental merges regenerated content into existing notes and leaves
+	// unchanged notes untouched. Otherwise every note is overwritten.
+	Incremental bool
+}
+
+// Report lists what a write did, by path relative to the vault.
+type Report struct {
+	Written   []string `json:"written"`
+	Unchanged []string `json:"unchanged"`
+	// Skipped notes already exist without markers, so there is nothing
+	// safe to replace in them.
+	Skipped []string `json:"skipped"`
+}
+
+// Write exports books into the vault.
+func (v Vault) Write(books []models.Book) (Report, error) {
+	var report Report
+
+	names := bookNames(books)
+	byAuthor := make(map[string][]models.Book)
+	bySeries := make(map[string][]models.Book)
+	for _, book := range books {
+		if book.Author != "" {
+			byAuthor[book.Author] = append(byAuthor[book.Author], book)
+		}
+		if book.Series != "" {
+			bySeries[book.Series] = append(bySeries[book.Series], book)
+		}
+	}
+
+	for _, book := range books {
+		if err := v.write(&report, filepath.Join("Books", names[book.ID]+".md"), bookNote(book)); err != nil {
+			return report, err
+		}
+	}
+	for _, author := range sortedKeys(byAuthor) {
+		note := authorNote(author, byAuthor[author], names)
+		if err := v.write(&report, filepath.Join("Authors", FileName(author)+".md"), note); err != nil {
+			return report, err
+		}
+	}
+	for _, series := range sortedKeys(bySeries) {
+		note := seriesNote(series, bySeries[series], names)
+		if err := v.write(&report, filepath.Join("Series", FileName(series)+".md"), note); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func (v Vault) write(report *Report, name string, generated note) error {
+	path := filepath.Join(v.Dir, name)
+	content := generated.String()
+
+	if v.Incremental {
+		existing, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			merged, ok := merge(string(existing), generated)
+			if !ok {
+				report.Skipped = append(report.Skipped, name)
+				return nil
+			}
+			if merged == string(existing) {
+				report.Unchanged = append(report.Unchanged, name)
+				return nil
+			}
+			content = merged
+		case !os.IsNotExist(err):
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	report.Written = append(report.Written, name)
+	return nil
+}
+
+// note is a generated Markdown note.
+type note struct {
+	Properties []property
+	Body       string // the managed section, without markers
+}
+
+func (n note) String() string {
+	var b strings.Builder
+	writeFrontMatter(&b, n.Properties)
+	b.WriteString(BeginMarker + "\n")
+	b.WriteString(n.Body)
+	b.WriteString(EndMarker + "\n")
+	return b.String()
+}
+
+// merge replaces the generated parts of an existing note: the managed
+// section and the properties the library defines. It reports false when the
+// note has no managed section.
+func merge(existing string, generated note) (string, bool) {
+	frontMatter, body := splitFrontMatter(existing)
+
+	begin := strings.Index(body, BeginMarker)
+	end := strings.Index(body, EndMarker)
+	if begin < 0 || end < begin {
+		return "", false
+	}
+	end += len(EndMarker)
+	if end < len(body) && body[end] == '\n' {
+		end++
+	}
+
+	defined := make(map[string]bool, len(generated.Properties))
+	for _, p := range generated.Properties {
+		defined[p.Key] = true
+	}
+
+	var b strings.Builder
+	props := generated.Properties
+	for _, block := range frontMatterBlocks(frontMatter) {
+		if !defined[block.key] {
+			props = append(props, property{Key: block.key, raw: block.text})
+		}
+	}
+	writeFrontMatter(&b, props)
+	b.WriteString(body[:begin])
+	b.WriteString(BeginMarker + "\n")
+	b.WriteString(generated.Body)
+	b.WriteString(EndMarker + "\n")
+	b.WriteString(body[end:])
+	return b.String(), true
+}
+
+func bookNote(book models.Book) note {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", book.Name)
+	if book.Cover != "" {
+		fmt.Fprintf(&b, "![cover](%s)\n\n", book.Cover)
+	}
+	if book.Author != "" {
+		fmt.Fprintf(&b, "**Author:** %s\n", link("Authors", book.Author))
+	}
+	if book.Series != "" {
+		series := link("Series", book.Series)
+		if book.SeriesOrder > 0 {
+			series += fmt.Sprintf(" #%d", book.SeriesOrder)
+		}
+		fmt.Fprintf(&b, "**Series:** %s\n", series)
+	}
+	if book.Rating > 0 {
+		fmt.Fprintf(&b, "**Rating:** %s\n", models.Stars(book.Rating))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(book.Description))
+	}
+	if book.Notes != "" {
+		fmt.Fprintf(&b, "\n## Notes\n\n%s\n", strings.TrimSpace(book.Notes))
+	}
+	return note{Properties: bookProperties(book), Body: b.String()}
+}
+
+func authorNote(author string, books []models.Book, names map[string]string) note {
+	sortBooks(books)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n## Books\n\n", author)
+	for _, book := range books {
+		fmt.Fprintf(&b, "- %s", bookLink(book, names))
+		if book.Series != "" {
+			fmt.Fprintf(&b, " (%s)", link("Series", book.Series))
+		}
+		b.WriteString("\n")
+	}
+	return note{
+		Properties: []property{stringProperty("type", "author"), stringProperty("name", author)},
+		Body:       b.String(),
+	}
+}
+
+func seriesNote(series string, books []models.Book, names map[string]string) note {
+	sortBooks(books)
+
+	var authors []string
+	for _, book := range books {
+		if book.Author != "" && !contains(authors, book.Author) {
+			authors = append(authors, book.Author)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", series)
+	if len(authors) > 0 {
+		links := make([]string, len(authors))
+		for i, author := range authors {
+			links[i] = link("Authors", author)
+		}
+		fmt.Fprintf(&b, "**By:** %s\n\n", strings.Join(links, ", "))
+	}
+	b.WriteString("## Books\n\n")
+	for _, book := range books {
+		if book.SeriesOrder > 0 {
+			fmt.Fprintf(&b, "%d. %s\n", book.SeriesOrder, bookLink(book, names))
+		} else {
+			fmt.Fprintf(&b, "- %s\n", bookLink(book, names))
+		}
+	}
+	return note{
+		Properties: []property{stringProperty("type", "series"), stringProperty("name", series)},
+		Body:       b.String(),
+	}
+}
+
+// link is a wiki-link to a note, qualified by folder so that an author and
+// a book with the same name do not collide.
+func link(folder, name string) string {
+	return "[[" + folder + "/" + FileName(name) + "|" + name + "]]"
+}
+
+func bookLink(book models.Book, names map[string]string) string {
+	return "[[Books/" + names[book.ID] + "|" + book.Name + "]]"
+}
+
+// FileName turns a title into a note name, dropping the characters Obsidian
+// does not allow in file names or links.
+func FileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '*', '"', '\\', '/', '<', '>', ':', '|', '?', '#', '^', '[', ']':
+			return ' '
+		}
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.TrimLeft(name, ".")
+}
+
+// bookNames picks a unique note name for every book, keyed by ID. Books
+// sharing a title are told apart by their ID.
+func bookNames(books []models.Book) map[string]string {
+	count := make(map[string]int)
+	for _, book := range books {
+		count[strings.ToLower(FileName(book.Name))]++
+	}
+
+	names := make(map[string]string, len(books))
+	for _, book := range books {
+		name := FileName(book.Name)
+		if name == "" || count[strings.ToLower(name)] > 1 {
+			name = strings.TrimSpace(name + " " + FileName(book.ID))
+		}
+		names[book.ID] = name
+	}
+	return names
+}
+
+// sortBooks orders books by series position, then title.
+func sortBooks(books []models.Book) {
+	sort.SliceStable(books, func(i, j int) bool {
+		if books[i].Series != books[j].Series {
+			return books[i].Series < books[j].Series
+		}
+		if books[i].SeriesOrder != books[j].SeriesOrder {
+			return books[i].SeriesOrder < books[j].SeriesOrder
+		}
+		return books[i].Name < books[j].Name
+	})
+}
+
+func sortedKeys(m map[string][]models.Book) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFrontMatter separates a leading YAML front matter block, without its
+// delimiters, from the rest of a note.
+func splitFrontMatter(s string) (frontMatter, body string) {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if !strings.HasPrefix(s, "---\n") {
+		return "", s
+	}
+	rest := s[len("---\n"):]
+	if strings.HasPrefix(rest, "---\n") {
+		return "", rest[len("---\n"):]
+	}
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n---") {
+			return rest[:len(rest)-len("\n---")], ""
+		}
+		return "", s
+	}
+	return rest[:end+1], rest[end+len("\n---\n"):]
+}
+
+type block struct {
+	key  string
+	text string
+}
+
+// frontMatterBlocks splits front matter into its top-level properties, each
+// with any indented or list lines that follow it.
+func frontMatterBlocks(frontMatter string) []block {
+	var blocks []block
+	for _, line := range strings.SplitAfter(frontMatter, "\n") {
+		if line == "" {
+			continue
+		}
+		top := line[0] != ' ' && line[0] != '\t' && line[0] != '-' && line[0] != '#'
+		if key, _, ok := strings.Cut(line, ":"); top && ok {
+			blocks = append(blocks, block{key: strings.TrimSpace(key), text: line})
+			continue
+		}
+		if len(blocks) > 0 {
+			blocks[len(blocks)-1].text += line
+		}
+	}
+	for i := range blocks {
+		blocks[i].text = strings.TrimRight(blocks[i].text, "\n")
+	}
+	return blocks
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	generated := note{
+		Properties: []property{stringProperty("name", "Dune"), intProperty("pages", 412)},
+		Body:       "# Dune\n",
+	}
+
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+		ok       bool
+	}{
+		{
+			name: "replaces the managed section and defined properties",
+			existing: "---\nname: \"Old\"\npages: 100\n---\n" +
+				BeginMarker + "\n# Old\n" + EndMarker + "\n",
+			want: "---\nname: \"Dune\"\npages: 412\n---\n" +
+				BeginMarker + "\n# Dune\n" + EndMarker + "\n",
+			ok: true,
+		},
+		{
+			name: "keeps the user's text around the section",
+			existing: "---\nname: \"Old\"\n---\nBefore\n" +
+				BeginMarker + "\n# Old\n" + EndMarker + "\n\n## My thoughts\nGreat.\n",
+			want: "---\nname: \"Dune\"\npages: 412\n---\nBefore\n" +
+				BeginMarker + "\n# Dune\n" + EndMarker + "\n\n## My thoughts\nGreat.\n",
+			ok: true,
+		},
+		{
+			name: "keeps the user's properties, lists included",
+			existing: "---\nname: \"Old\"\nshelf: \"favourites\"\naliases:\n  - \"Dune 1\"\n---\n" +
+				BeginMarker + "\n# Old\n" + EndMarker + "\n",
+			want: "---\nname: \"Dune\"\npages: 412\nshelf: \"favourites\"\naliases:\n  - \"Dune 1\"\n---\n" +
+				BeginMarker + "\n# Dune\n" + EndMarker + "\n",
+			ok: true,
+		},
+		{
+			name:     "adds front matter to a note without any",
+			existing: BeginMarker + "\n# Old\n" + EndMarker,
+			want: "---\nname: \"Dune\"\npages: 412\n---\n" +
+				BeginMarker + "\n# Dune\n" + EndMarker + "\n",
+			ok: true,
+		},
+		{
+			name:     "no managed section",
+			existing: "---\nname: \"Mine\"\n---\n# My own note\n",
+		},
+		{
+			name:     "markers out of order",
+			existing: EndMarker + "\n# Old\n" + BeginMarker + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := merge(tt.existing, generated)
+			if ok != tt.ok {
+				t.Fatalf("merge ok = %v, want %v", ok, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("merge =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeIsStable(t *testing.T) {
+	generated := note{Properties: []property{stringProperty("name", "Dune")}, Body: "# Dune\n"}
+	existing := "---\nname: \"Old\"\nshelf: \"favourites\"\n---\nIntro\n" + BeginMarker + "\n# Old\n" + EndMarker + "\nOutro\n"
+
+	once, ok := merge(existing, generated)
+	if !ok {
+		t.Fatal("merge found no managed section")
+	}
+	twice, _ := merge(once, generated)
+	if twice != once {
+		t.Errorf("merging again changed the note:\n%s\nthen\n%s", once, twice)
+	}
+	if !strings.Contains(once, "Intro\n") || !strings.Contains(once, "Outro\n") {
+		t.Errorf("merge lost the user's text:\n%s", once)
+	}
+}
@@ -0,0 +1,44 @@
+// Package web holds the link helpers shared by the HTTP handlers, the feeds
+// and the OPDS catalog.
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BaseURL returns the scheme and host a request was made to, honouring the
+// X-Forwarded-Proto header set by proxies such as Heroku's router.
+func BaseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + req.Host
+}
+
+// AbsoluteURL resolves a site-relative link such as /covers/abc.jpg against
+// base. Absolute links are returned unchanged.
+func AbsoluteURL(base, link string) string {
+	if link == "" || strings.Contains(link, "://") {
+		return link
+	}
+	return base + "/" + strings.TrimPrefix(link, "/")
+}
+
+// ImageType guesses an image media type from its URL.
+func ImageType(href string) string {
+	lower := strings.ToLower(href)
+	switch {
+	case strings.Contains(lower, ".png"):
+		return "image/png"
+	case strings.Contains(lower, ".gif"):
+		return "image/gif"
+	case strings.Contains(lower, ".svg"):
+		return "image/svg+xml"
+	}
+	return "image/jpeg"
+}